@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
+	"strings"
+	"time"
+)
+
+// newAdminHandler creates handler for the admin listener.
+// All endpoints require "Authorization: Bearer <token>" header.
+// Preview links endpoints are registered only if links is not nil.
+func newAdminHandler(token string, level *slog.LevelVar, m *maintenance, retryAfter time.Duration, links *previewLinks) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/goroutines", goroutines())
+
+	mux.HandleFunc("GET /log/level", getLogLevel(level))
+	mux.HandleFunc("PUT /log/level", setLogLevel(level))
+
+	mux.HandleFunc("GET /maintenance", getMaintenance(m))
+	mux.HandleFunc("PUT /maintenance", enableMaintenance(m, retryAfter))
+	mux.HandleFunc("DELETE /maintenance", disableMaintenance(m))
+
+	if links != nil {
+		mux.HandleFunc("POST /preview/links", createPreviewLink(links))
+		mux.HandleFunc("DELETE /preview/links/{id}", revokePreviewLink(links))
+	}
+
+	return TokenAuthMiddleware(token, mux)
+}
+
+// TokenAuthMiddleware allows only requests with bearer token.
+func TokenAuthMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// goroutines dumps stacks of all goroutines.
+func goroutines() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+	}
+}
+
+type logLevel struct {
+	Level slog.Level `json:"level"`
+}
+
+func getLogLevel(level *slog.LevelVar) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, logLevel{Level: level.Level()})
+	}
+}
+
+func setLogLevel(level *slog.LevelVar) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req logLevel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		slog.Info("changing log level", "from", level.Level(), "to", req.Level)
+		level.Set(req.Level)
+		writeJSON(w, http.StatusOK, logLevel{Level: level.Level()})
+	}
+}
+
+type maintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+	// RetryAfter is in seconds.
+	RetryAfter int64 `json:"retry_after,omitempty"`
+}
+
+func getMaintenance(m *maintenance) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, newMaintenanceStatus(m))
+	}
+}
+
+func enableMaintenance(m *maintenance, retryAfter time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req maintenanceStatus
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+		after := retryAfter
+		if req.RetryAfter > 0 {
+			after = time.Duration(req.RetryAfter) * time.Second
+		}
+
+		slog.Warn("enabling maintenance mode", "retry_after", after)
+		m.Enable(after)
+		writeJSON(w, http.StatusOK, newMaintenanceStatus(m))
+	}
+}
+
+func disableMaintenance(m *maintenance) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Warn("disabling maintenance mode")
+		m.Disable()
+		writeJSON(w, http.StatusOK, newMaintenanceStatus(m))
+	}
+}
+
+func newMaintenanceStatus(m *maintenance) maintenanceStatus {
+	if !m.Enabled() {
+		return maintenanceStatus{}
+	}
+
+	return maintenanceStatus{
+		Enabled:    true,
+		RetryAfter: int64(m.RetryAfter().Seconds()),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminAuth(t *testing.T) {
+	var level slog.LevelVar
+	handler := newAdminHandler("secret", &level, &maintenance{}, time.Minute, nil)
+
+	tests := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{name: "no token", code: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer nope", code: http.StatusUnauthorized},
+		{name: "not bearer", header: "Basic secret", code: http.StatusUnauthorized},
+		{name: "valid token", header: "Bearer secret", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	var level slog.LevelVar
+	handler := newAdminHandler("secret", &level, &maintenance{}, time.Minute, nil)
+
+	rec := adminRequest(t, handler, http.MethodPut, "/log/level", `{"level":"debug"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Fatalf("expected level DEBUG, got %s", level.Level())
+	}
+
+	rec = adminRequest(t, handler, http.MethodGet, "/log/level", "")
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"level":"DEBUG"}` {
+		t.Fatalf("unexpected response: %s", got)
+	}
+
+	rec = adminRequest(t, handler, http.MethodPut, "/log/level", `{"level":"verbose"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	var level slog.LevelVar
+	var m maintenance
+	admin := newAdminHandler("secret", &level, &m, time.Minute, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/health", health(nil))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("blog"))
+	})
+	public := MaintenanceMiddleware(&m, mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		public.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/"); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 before maintenance, got %d", rec.Code)
+	}
+
+	rec := adminRequest(t, admin, http.MethodPut, "/maintenance", `{"retry_after":120}`)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"enabled":true,"retry_after":120}` {
+		t.Fatalf("unexpected response: %s", got)
+	}
+
+	rec = get("/blog/")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 in maintenance, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "120" {
+		t.Fatalf("expected Retry-After 120, got %q", got)
+	}
+	if rec := get("/-/health"); rec.Code != http.StatusOK {
+		t.Fatalf("expected health to work in maintenance, got %d", rec.Code)
+	}
+
+	adminRequest(t, admin, http.MethodDelete, "/maintenance", "")
+	if rec := get("/"); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 after maintenance, got %d", rec.Code)
+	}
+
+	rec = adminRequest(t, admin, http.MethodPut, "/maintenance", "")
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"enabled":true,"retry_after":60}` {
+		t.Fatalf("expected default retry after, got %s", got)
+	}
+}
+
+func adminRequest(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
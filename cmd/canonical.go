@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var canonicalLinkRe = regexp.MustCompile(`<link[^>]+rel=["']?canonical["']?[^>]+href=["']?([^"' >]+)`)
+
+// canonicalBaseURL returns canonical base URL of the site.
+// If baseURL is empty, it is taken from the canonical link of the index.html in fsys.
+func canonicalBaseURL(baseURL string, fsys fs.FS) (*url.URL, error) {
+	if baseURL == "" {
+		index, err := fs.ReadFile(fsys, "index.html")
+		if err != nil {
+			return nil, fmt.Errorf("read index.html: %w", err)
+		}
+
+		m := canonicalLinkRe.FindSubmatch(index)
+		if m == nil {
+			return nil, errors.New("no canonical link in index.html")
+		}
+		baseURL = string(m[1])
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("base URL %q is not absolute", baseURL)
+	}
+
+	return u, nil
+}
+
+// CanonicalMiddleware permanently redirects requests to the canonical URL:
+// canonical scheme and host, no double slashes and trailing slash for directories in fsys.
+// Service endpoints under /-/ are never redirected.
+func CanonicalMiddleware(canonical *url.URL, fsys fs.FS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/-/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := canonicalPath(r.URL.Path, fsys)
+		if p == r.URL.Path &&
+			strings.EqualFold(requestHost(r), canonical.Host) &&
+			requestScheme(r, canonical.Scheme) == canonical.Scheme {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target := *r.URL
+		target.Scheme = canonical.Scheme
+		target.Host = canonical.Host
+		target.Path = p
+		target.RawPath = ""
+
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect // keep the method and body
+		}
+		http.Redirect(w, r, target.String(), code)
+	})
+}
+
+// requestScheme returns the scheme the client used.
+// Plain HTTP requests without X-Forwarded-Proto are assumed to come from a TLS-terminating proxy,
+// so they are reported as the canonical scheme to avoid redirect loops.
+func requestScheme(r *http.Request, canonical string) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+
+	return canonical
+}
+
+func requestHost(r *http.Request) string {
+	if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+		return strings.TrimSpace(strings.Split(host, ",")[0])
+	}
+
+	return r.Host
+}
+
+// canonicalPath collapses double slashes and adds trailing slash to directories.
+func canonicalPath(p string, fsys fs.FS) string {
+	if p == "" {
+		return "/"
+	}
+
+	cleaned := path.Clean(p)
+	if cleaned == "/" {
+		return cleaned
+	}
+
+	if strings.HasSuffix(p, "/") || isDir(fsys, strings.TrimPrefix(cleaned, "/")) {
+		return cleaned + "/"
+	}
+
+	return cleaned
+}
+
+func isDir(fsys fs.FS, name string) bool {
+	if path.Ext(name) != "" {
+		return false
+	}
+
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCanonicalMiddleware(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":                   {Data: []byte(`<link rel="canonical" href="https://getpid.dev/">`)},
+		"blog/golden-tests/index.html": {Data: []byte("golden")},
+		"css/style.css":                {Data: []byte("body{}")},
+	}
+
+	canonical, err := canonicalBaseURL("", fsys)
+	if err != nil {
+		t.Fatalf("canonical base URL: %s", err)
+	}
+
+	handler := CanonicalMiddleware(canonical, fsys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		headers  map[string]string
+		code     int
+		location string
+	}{
+		{
+			name: "canonical",
+			url:  "https://getpid.dev/blog/golden-tests/",
+			code: http.StatusOK,
+		},
+		{
+			name: "file",
+			url:  "https://getpid.dev/css/style.css",
+			code: http.StatusOK,
+		},
+		{
+			name: "plain HTTP behind TLS proxy",
+			url:  "http://getpid.dev/blog/",
+			code: http.StatusOK,
+		},
+		{
+			name:     "www",
+			url:      "https://www.getpid.dev/blog/golden-tests/?q=1",
+			code:     http.StatusMovedPermanently,
+			location: "https://getpid.dev/blog/golden-tests/?q=1",
+		},
+		{
+			name:     "wrong host",
+			url:      "https://example.com/",
+			code:     http.StatusMovedPermanently,
+			location: "https://getpid.dev/",
+		},
+		{
+			name:     "forwarded HTTP",
+			url:      "http://getpid.dev/",
+			headers:  map[string]string{"X-Forwarded-Proto": "http"},
+			code:     http.StatusMovedPermanently,
+			location: "https://getpid.dev/",
+		},
+		{
+			name:     "missing trailing slash",
+			url:      "https://getpid.dev/blog/golden-tests",
+			code:     http.StatusMovedPermanently,
+			location: "https://getpid.dev/blog/golden-tests/",
+		},
+		{
+			name:     "double slashes",
+			url:      "https://getpid.dev//blog//golden-tests/",
+			code:     http.StatusMovedPermanently,
+			location: "https://getpid.dev/blog/golden-tests/",
+		},
+		{
+			name:     "non-GET keeps method",
+			method:   http.MethodPost,
+			url:      "https://www.getpid.dev/",
+			code:     http.StatusPermanentRedirect,
+			location: "https://getpid.dev/",
+		},
+		{
+			name: "health is exempt",
+			url:  "http://10.0.0.1:8080/-/health",
+			code: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.url, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Fatalf("expected location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
+
+func TestCanonicalBaseURLFromConfig(t *testing.T) {
+	u, err := canonicalBaseURL("https://getpid.dev/", fstest.MapFS{})
+	if err != nil {
+		t.Fatalf("canonical base URL: %s", err)
+	}
+	if u.Host != "getpid.dev" {
+		t.Fatalf("expected host getpid.dev, got %s", u.Host)
+	}
+
+	if _, err := canonicalBaseURL("localhost:8080", fstest.MapFS{}); err == nil {
+		t.Fatal("expected error for URL without scheme")
+	}
+}
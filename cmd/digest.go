@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/rss"
+)
+
+// digestState is what the digest has already been sent about.
+type digestState struct {
+	// Sent are GUIDs of sent posts.
+	Sent   []string  `json:"sent"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// loadDigestState reads state file, it returns false if the file doesn't exist.
+func loadDigestState(file string) (digestState, bool, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return digestState{}, false, nil
+	}
+	if err != nil {
+		return digestState{}, false, fmt.Errorf("read digest state: %w", err)
+	}
+
+	var state digestState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return digestState{}, false, fmt.Errorf("decode digest state: %w", err)
+	}
+
+	return state, true, nil
+}
+
+func saveDigestState(file string, state digestState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Dir(file), filepath.Base(file), data)
+}
+
+// newPosts returns items of the feed not in the state, oldest first.
+func newPosts(ch *rss.Channel, state digestState) []rss.Item {
+	sent := make(map[string]bool, len(state.Sent))
+	for _, guid := range state.Sent {
+		sent[guid] = true
+	}
+
+	var items []rss.Item
+	for _, it := range ch.Items {
+		if !sent[it.GUID] {
+			items = append(items, it)
+		}
+	}
+	slices.SortStableFunc(items, func(a, b rss.Item) int { return a.PubDate.Compare(b.PubDate) })
+
+	return items
+}
+
+// sendDigest mails posts of the feed which were not sent yet to confirmed subscribers and records them in the state file.
+// There is nothing to diff against on the first run, so the posts are only recorded and subscribers don't get the whole archive.
+// Posts are recorded as sent even if sending to some subscribers fails, so others don't get them twice.
+func sendDigest(ch *rss.Channel, n *newsletter, stateFile string) (int, error) {
+	state, ok, err := loadDigestState(stateFile)
+	if err != nil {
+		return 0, err
+	}
+
+	posts := newPosts(ch, state)
+	for _, p := range posts {
+		state.Sent = append(state.Sent, p.GUID)
+	}
+
+	var sendErr error
+	if ok && len(posts) > 0 {
+		subject := fmt.Sprintf("%d new posts on %s", len(posts), n.siteName)
+		if len(posts) == 1 {
+			subject = fmt.Sprintf("New post on %s: %s", n.siteName, posts[0].Title)
+		}
+
+		for _, email := range n.subs.Confirmed() {
+			unsubscribeURL := n.UnsubscribeURL(email)
+			if err := n.mailer.Send(email, subject, digestBody(n.siteName, posts, unsubscribeURL), unsubscribeURL); err != nil {
+				slog.Error("failed to send digest", "error", err)
+				sendErr = errors.Join(sendErr, err)
+			}
+		}
+		state.SentAt = n.tokens.now()
+	} else if !ok {
+		slog.Info("no digest state, recording posts without sending", "posts", len(posts))
+		posts = nil
+	}
+
+	if err := saveDigestState(stateFile, state); err != nil {
+		return 0, errors.Join(sendErr, fmt.Errorf("save digest state: %w", err))
+	}
+
+	return len(posts), sendErr
+}
+
+func digestBody(siteName string, posts []rss.Item, unsubscribeURL string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "New on %s:\n", siteName)
+	for _, p := range posts {
+		fmt.Fprintf(&b, "\n%s\n", p.Title)
+		if desc := strings.TrimSpace(plainText(p.Description)); desc != "" {
+			fmt.Fprintf(&b, "%s\n", desc)
+		}
+		fmt.Fprintf(&b, "%s\n", p.Link)
+	}
+	fmt.Fprintf(&b, "\n-- \nUnsubscribe: %s\n", unsubscribeURL)
+
+	return b.String()
+}
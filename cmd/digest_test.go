@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/rss"
+)
+
+func TestSendDigest(t *testing.T) {
+	stub := newSMTPStub(t)
+	n := newTestNewsletter(t, stub.Addr())
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	if err := n.subs.Confirm("reader@example.com", now); err != nil {
+		t.Fatalf("confirm subscriber: %s", err)
+	}
+	if _, err := n.subs.Add("pending@example.com", now); err != nil {
+		t.Fatalf("add subscriber: %s", err)
+	}
+	stateFile := filepath.Join(t.TempDir(), "state", "digest.json")
+
+	webview := rss.Item{
+		Title:   "Webview",
+		Link:    "https://getpid.dev/blog/go-webview-gui/",
+		GUID:    "https://getpid.dev/blog/go-webview-gui/",
+		PubDate: time.Date(2025, 5, 28, 22, 2, 23, 0, time.UTC),
+	}
+	golden := rss.Item{
+		Title:       "Golden Tests",
+		Link:        "https://getpid.dev/blog/golden-tests/",
+		GUID:        "https://getpid.dev/blog/golden-tests/",
+		PubDate:     time.Date(2025, 6, 4, 18, 27, 15, 0, time.UTC),
+		Description: "<p>Use golden files for testing APIs &amp; CLIs</p>", // RSS description is HTML
+	}
+
+	// the first run only records existing posts
+	sent, err := sendDigest(&rss.Channel{Items: []rss.Item{webview}}, n, stateFile)
+	if err != nil {
+		t.Fatalf("send digest: %s", err)
+	}
+	if sent != 0 || len(stub.Messages()) != 0 {
+		t.Fatalf("expected nothing sent on the first run, got %d posts, %d emails", sent, len(stub.Messages()))
+	}
+
+	sent, err = sendDigest(&rss.Channel{Items: []rss.Item{golden, webview}}, n, stateFile)
+	if err != nil {
+		t.Fatalf("send digest: %s", err)
+	}
+	if sent != 1 {
+		t.Fatalf("expected 1 new post, got %d", sent)
+	}
+
+	messages := stub.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "reader@example.com" {
+		t.Fatalf("expected digest to confirmed subscriber only, got %v", messages)
+	}
+	header := messages[0].Header(t)
+	if got := header.Get("Subject"); got != "New post on getpid.dev: Golden Tests" {
+		t.Errorf("unexpected subject %q", got)
+	}
+	if got := header.Get("List-Unsubscribe"); !strings.HasPrefix(got, "<https://getpid.dev/api/newsletter/unsubscribe?token=") {
+		t.Errorf("unexpected List-Unsubscribe %q", got)
+	}
+	body := messages[0].Body(t)
+	if !strings.Contains(body, "Golden Tests\nUse golden files for testing APIs & CLIs\nhttps://getpid.dev/blog/golden-tests/") {
+		t.Errorf("expected post in digest, got:\n%s", body)
+	}
+	if strings.Contains(body, "Webview") {
+		t.Errorf("expected already sent post not to be in digest, got:\n%s", body)
+	}
+
+	sent, err = sendDigest(&rss.Channel{Items: []rss.Item{golden, webview}}, n, stateFile)
+	if err != nil {
+		t.Fatalf("send digest: %s", err)
+	}
+	if sent != 0 || len(stub.Messages()) != 1 {
+		t.Fatalf("expected nothing sent without new posts, got %d posts, %d emails", sent, len(stub.Messages()))
+	}
+}
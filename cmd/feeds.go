@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/rss"
+)
+
+const (
+	jsonFeedContentType = "application/feed+json"
+	atomContentType     = "application/atom+xml; charset=utf-8"
+)
+
+// renderedFeed is a feed rendered once at startup, content of the site doesn't change while running.
+type renderedFeed struct {
+	data        []byte
+	contentType string
+	etag        string
+	modified    time.Time
+}
+
+func newRenderedFeed(data []byte, contentType string, modified time.Time) renderedFeed {
+	sum := sha256.Sum256(data)
+	return renderedFeed{
+		data:        data,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		modified:    modified,
+	}
+}
+
+// feeds are JSON Feed and Atom versions of the RSS feed generated by Hugo.
+type feeds struct {
+	json renderedFeed
+	atom renderedFeed
+}
+
+// loadFeeds parses index.xml of the site and renders feeds. Author of the site is siteName, RSS of Hugo doesn't have one.
+func loadFeeds(fsys fs.FS, siteName string) (*feeds, error) {
+	f, err := fsys.Open("index.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ch, err := rss.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	modified := ch.LastBuildDate
+	for _, it := range ch.Items {
+		if it.PubDate.After(modified) {
+			modified = it.PubDate
+		}
+	}
+
+	var jsonData bytes.Buffer
+	jsonEnc := json.NewEncoder(&jsonData)
+	jsonEnc.SetEscapeHTML(false) // content is HTML, no need to escape it twice
+	jsonEnc.SetIndent("", "  ")
+	if err := jsonEnc.Encode(newJSONFeed(ch, siteName)); err != nil {
+		return nil, fmt.Errorf("encode JSON feed: %w", err)
+	}
+
+	var atomData bytes.Buffer
+	atomData.WriteString(xml.Header)
+	enc := xml.NewEncoder(&atomData)
+	enc.Indent("", "  ")
+	if err := enc.Encode(newAtomFeed(ch, siteName, modified)); err != nil {
+		return nil, fmt.Errorf("encode Atom feed: %w", err)
+	}
+
+	return &feeds{
+		json: newRenderedFeed(jsonData.Bytes(), jsonFeedContentType, modified),
+		atom: newRenderedFeed(atomData.Bytes(), atomContentType, modified),
+	}, nil
+}
+
+// jsonFeed is JSON Feed 1.1, see https://www.jsonfeed.org/version/1.1/.
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	ContentText   string    `json:"content_text"`
+	Summary       string    `json:"summary,omitempty"`
+	Image         string    `json:"image,omitempty"`
+	DatePublished time.Time `json:"date_published,omitzero"`
+	Tags          []string  `json:"tags,omitempty"`
+}
+
+func newJSONFeed(ch *rss.Channel, siteName string) jsonFeed {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       ch.Title,
+		HomePageURL: ch.Link,
+		FeedURL:     feedURL(ch.Link, "feed.json"),
+		Description: ch.Description,
+		Language:    ch.Language,
+		Authors:     []jsonFeedAuthor{{Name: siteName}},
+		Items:       make([]jsonFeedItem, 0, len(ch.Items)),
+	}
+	for _, it := range ch.Items {
+		summary := plainText(it.Description)
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            it.GUID,
+			URL:           it.Link,
+			Title:         it.Title,
+			ContentText:   summary, // RSS of Hugo has only summaries, but JSON Feed requires content
+			Summary:       summary,
+			Image:         it.Image,
+			DatePublished: it.PubDate,
+			Tags:          it.Categories,
+		})
+	}
+
+	return feed
+}
+
+// atomFeed is Atom 1.0, see RFC 4287.
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string      `xml:"xml:lang,attr,omitempty"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Author   atomAuthor  `xml:"author"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func newAtomFeed(ch *rss.Channel, siteName string, updated time.Time) atomFeed {
+	feed := atomFeed{
+		Lang:     ch.Language,
+		ID:       ch.Link,
+		Title:    ch.Title,
+		Subtitle: ch.Description,
+		Updated:  updated.Format(time.RFC3339),
+		Author:   atomAuthor{Name: siteName},
+		Links: []atomLink{
+			{Href: ch.Link, Rel: "alternate", Type: "text/html"},
+			{Href: feedURL(ch.Link, "atom.xml"), Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, it := range ch.Items {
+		entry := atomEntry{
+			ID:        it.GUID,
+			Title:     it.Title,
+			Links:     []atomLink{{Href: it.Link, Rel: "alternate", Type: "text/html"}},
+			Published: it.PubDate.Format(time.RFC3339),
+			Updated:   it.PubDate.Format(time.RFC3339),
+		}
+		if it.Description != "" {
+			entry.Summary = &atomText{Type: "html", Text: it.Description}
+		}
+		for _, c := range it.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		if it.Image != "" {
+			entry.Links = append(entry.Links, atomLink{Href: it.Image, Rel: "enclosure", Type: imageType(it.Image)})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func feedURL(home, name string) string {
+	u, err := url.Parse(home)
+	if err != nil || home == "" {
+		return ""
+	}
+
+	return u.JoinPath(name).String()
+}
+
+func imageType(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return ""
+	}
+
+	return mime.TypeByExtension(path.Ext(u.Path))
+}
+
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// plainText strips tags from HTML summary.
+func plainText(s string) string {
+	return html.UnescapeString(htmlTagRe.ReplaceAllString(s, ""))
+}
+
+// serveFeed serves rendered feed with ETag and Last-Modified, so readers polling it get 304 Not Modified.
+func serveFeed(f renderedFeed) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", f.contentType)
+		w.Header().Set("ETag", f.etag)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		http.ServeContent(w, r, "", f.modified, bytes.NewReader(f.data))
+	}
+}
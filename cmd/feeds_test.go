@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"example.com/golden/golden"
+)
+
+func TestFeeds(t *testing.T) {
+	siteFeeds, err := loadFeeds(os.DirFS("testdata/site"), "getpid.dev")
+	if err != nil {
+		t.Fatalf("load feeds: %s", err)
+	}
+
+	tests := []struct {
+		name            string
+		feed            renderedFeed
+		wantContentType string
+	}{
+		{name: "feed.json", feed: siteFeeds.json, wantContentType: "application/feed+json"},
+		{name: "atom.xml", feed: siteFeeds.atom, wantContentType: "application/atom+xml; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(serveFeed(tt.feed))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tt.name, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("expected content type %q, got %q", tt.wantContentType, got)
+			}
+			if got := rec.Header().Get("Last-Modified"); got != "Wed, 04 Jun 2025 16:27:15 GMT" {
+				t.Errorf("expected Last-Modified of the newest post, got %q", got)
+			}
+			golden.Assert(t, "feeds/"+tt.name+".golden", rec.Body.Bytes())
+
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.name, nil)
+			req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusNotModified {
+				t.Fatalf("expected status 304 for matching ETag, got %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/resize"
+)
+
+// defaultImageQuality is used when quality is not requested, it is always allowed.
+const defaultImageQuality = 75
+
+// imageResizer resizes PNG and JPEG images of the site to allowed widths and qualities.
+// Resized images are cached in a bounded in-memory LRU and on disk.
+type imageResizer struct {
+	fsys      fs.FS
+	widths    []int
+	qualities []int
+	// dir is the disk cache directory, disk cache is disabled if empty.
+	dir string
+	// sem limits concurrent resizing, it is CPU and memory heavy.
+	sem chan struct{}
+
+	// flight resizes an image once for concurrent requests of it.
+	flight singleflight[[]byte]
+
+	mu      sync.Mutex
+	sources map[string]sourceImage
+	cache   *lru
+}
+
+// sourceImage describes an image of the site.
+type sourceImage struct {
+	// version is hash of the image, the same as the render-image hook puts into URLs.
+	version string
+	// contentType is detected from the image content, resized images keep the format.
+	contentType string
+}
+
+func newImageResizer(fsys fs.FS, widths, qualities []int, dir string, cacheBytes int64) *imageResizer {
+	return &imageResizer{
+		fsys:      fsys,
+		widths:    widths,
+		qualities: qualities,
+		dir:       dir,
+		sem:       make(chan struct{}, 2),
+		sources:   make(map[string]sourceImage),
+		cache:     newLRU(cacheBytes),
+	}
+}
+
+// source returns version and content type of the source image.
+func (ir *imageResizer) source(name string) (sourceImage, error) {
+	ir.mu.Lock()
+	s, ok := ir.sources[name]
+	ir.mu.Unlock()
+	if ok { // site files don't change, read them once
+		return s, nil
+	}
+
+	// read without the lock, it would block cache lookups of other images;
+	// concurrent first requests may read the same file, but store the same result
+	src, err := fs.ReadFile(ir.fsys, name)
+	if err != nil {
+		return sourceImage{}, err
+	}
+
+	contentType, err := resize.ContentType(src)
+	if err != nil {
+		return sourceImage{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	sum := sha256.Sum256(src)
+	s = sourceImage{
+		version:     hex.EncodeToString(sum[:])[:16],
+		contentType: contentType,
+	}
+
+	ir.mu.Lock()
+	ir.sources[name] = s
+	ir.mu.Unlock()
+
+	return s, nil
+}
+
+// Resize returns resized image, its content type and key, which changes with the source image.
+func (ir *imageResizer) Resize(name string, width, quality int) (data []byte, contentType, key, version string, err error) {
+	src, err := ir.source(name)
+	if err != nil {
+		return nil, "", "", "", err
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d:%d", name, src.version, width, quality)))
+	key = hex.EncodeToString(sum[:16])
+
+	ir.mu.Lock()
+	data, ok := ir.cache.Get(key)
+	ir.mu.Unlock()
+	if ok {
+		return data, src.contentType, key, src.version, nil
+	}
+
+	data, err = ir.flight.Do(key, func() ([]byte, error) {
+		return ir.load(name, key, width, quality)
+	})
+	if err != nil {
+		return nil, "", "", "", err
+	}
+
+	return data, src.contentType, key, src.version, nil
+}
+
+// load reads resized image from the disk cache or resizes it, and caches it.
+func (ir *imageResizer) load(name, key string, width, quality int) ([]byte, error) {
+	if ir.dir != "" {
+		data, err := os.ReadFile(filepath.Join(ir.dir, key+path.Ext(name)))
+		if err == nil {
+			ir.add(key, data)
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to read cached image", "key", key, "error", err)
+		}
+	}
+
+	src, err := fs.ReadFile(ir.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ir.sem <- struct{}{}
+	data, _, err := resize.Resize(src, width, quality) // in the format of the source, see sourceImage
+	<-ir.sem
+	if err != nil {
+		return nil, fmt.Errorf("resize %s: %w", name, err)
+	}
+
+	ir.add(key, data)
+	if ir.dir != "" {
+		if err := writeFileAtomic(ir.dir, key+path.Ext(name), data); err != nil { // still can serve it from memory
+			slog.Warn("failed to cache image on disk", "key", key, "error", err)
+		}
+	}
+
+	return data, nil
+}
+
+func (ir *imageResizer) add(key string, data []byte) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	ir.cache.Add(key, data)
+}
+
+// resizedImage serves resized image: /img/<path>?w=<width>&q=<quality>&v=<version>.
+// Width is required, quality is optional, both must be allowed to prevent filling caches with arbitrary sizes.
+// Responses are immutable if version matches the source image hash, URLs from the render-image hook have it.
+func resizedImage(ir *imageResizer) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("path")
+		switch path.Ext(name) {
+		case ".png", ".jpg", ".jpeg":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		width, err := strconv.Atoi(query.Get("w"))
+		if err != nil || !slices.Contains(ir.widths, width) {
+			http.Error(w, fmt.Sprintf("width must be one of %v", ir.widths), http.StatusBadRequest)
+			return
+		}
+
+		quality := defaultImageQuality
+		if q := query.Get("q"); q != "" {
+			quality, err = strconv.Atoi(q)
+			if err != nil || (quality != defaultImageQuality && !slices.Contains(ir.qualities, quality)) {
+				http.Error(w, fmt.Sprintf("quality must be one of %v", ir.qualities), http.StatusBadRequest)
+				return
+			}
+		}
+
+		data, contentType, key, version, err := ir.Resize(name, width, quality)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) ||
+			errors.Is(err, resize.ErrUnsupported) || errors.Is(err, resize.ErrInvalid) { // not a resizable image
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to resize image", "path", name, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", `"`+key+`"`)
+		if query.Get("v") == version {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else { // outdated or unversioned URL, source can change with the next deploy
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// lru is a cache of byte slices, bounded by their total size. It is not safe for concurrent use.
+type lru struct {
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	data []byte
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lru) Get(key string) ([]byte, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).data, true
+}
+
+// Add adds data to the cache, evicting least recently used entries. Data larger than the cache is not added.
+func (c *lru) Add(key string, data []byte) {
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, data: data})
+	c.size += int64(len(data))
+
+	for c.size > c.maxBytes {
+		e := c.ll.Back()
+		entry := e.Value.(*lruEntry)
+		c.ll.Remove(e)
+		delete(c.items, entry.key)
+		c.size -= int64(len(entry.data))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU(10)
+	c.Add("a", []byte("aaaa"))
+	c.Add("b", []byte("bbbb"))
+
+	if _, ok := c.Get("a"); !ok { // a is now the most recently used
+		t.Fatalf("expected a in cache")
+	}
+
+	c.Add("c", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s in cache", key)
+		}
+	}
+
+	c.Add("big", make([]byte, 11))
+	if _, ok := c.Get("big"); ok {
+		t.Errorf("expected data larger than cache not to be added")
+	}
+	if c.size != 8 {
+		t.Errorf("expected size 8, got %d", c.size)
+	}
+}
+
+func TestImageResizerDiskCache(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, image.NewGray(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatalf("encode image: %s", err)
+	}
+	fsys := fstest.MapFS{"images/screenshot.png": {Data: src.Bytes()}}
+	dir := t.TempDir()
+
+	ir := newImageResizer(fsys, []int{100}, nil, dir, 1<<20)
+	data, contentType, key, _, err := ir.Resize("images/screenshot.png", 100, defaultImageQuality)
+	if err != nil {
+		t.Fatalf("resize: %s", err)
+	}
+	if contentType != "image/png" {
+		t.Errorf("expected image/png, got %s", contentType)
+	}
+
+	cached, err := os.ReadFile(filepath.Join(dir, key+".png"))
+	if err != nil {
+		t.Fatalf("expected image cached on disk: %s", err)
+	}
+	if !bytes.Equal(cached, data) {
+		t.Errorf("expected cached image to be the same as resized")
+	}
+
+	// new instance, e.g. after restart, reads images from disk
+	if err := os.WriteFile(filepath.Join(dir, key+".png"), []byte("cached"), 0o644); err != nil {
+		t.Fatalf("write file: %s", err)
+	}
+	ir = newImageResizer(fsys, []int{100}, nil, dir, 1<<20)
+	data, _, _, _, err = ir.Resize("images/screenshot.png", 100, defaultImageQuality)
+	if err != nil {
+		t.Fatalf("resize: %s", err)
+	}
+	if string(data) != "cached" {
+		t.Errorf("expected image to be read from disk cache")
+	}
+}
+
+func TestImageResizerContentType(t *testing.T) {
+	var src bytes.Buffer
+	if err := jpeg.Encode(&src, image.NewGray(image.Rect(0, 0, 400, 200)), nil); err != nil {
+		t.Fatalf("encode image: %s", err)
+	}
+	// content type is detected from the content, not from the extension
+	fsys := fstest.MapFS{"images/photo.png": {Data: src.Bytes()}}
+	dir := t.TempDir()
+
+	for _, name := range []string{"resized", "from memory"} {
+		ir := newImageResizer(fsys, []int{100}, nil, dir, 1<<20)
+		if name == "from memory" {
+			if _, _, _, _, err := ir.Resize("images/photo.png", 100, defaultImageQuality); err != nil {
+				t.Fatalf("resize: %s", err)
+			}
+		}
+
+		_, contentType, _, _, err := ir.Resize("images/photo.png", 100, defaultImageQuality)
+		if err != nil {
+			t.Fatalf("%s: resize: %s", name, err)
+		}
+		if contentType != "image/jpeg" {
+			t.Errorf("%s: expected image/jpeg, got %s", name, contentType)
+		}
+	}
+
+	// from disk cache of the first resize
+	ir := newImageResizer(fsys, []int{100}, nil, dir, 1<<20)
+	_, contentType, _, _, err := ir.Resize("images/photo.png", 100, defaultImageQuality)
+	if err != nil {
+		t.Fatalf("resize: %s", err)
+	}
+	if contentType != "image/jpeg" {
+		t.Errorf("from disk: expected image/jpeg, got %s", contentType)
+	}
+}
+
+func TestResizedImageNotResizable(t *testing.T) {
+	var gifSrc, jpegSrc bytes.Buffer
+	if err := gif.Encode(&gifSrc, image.NewGray(image.Rect(0, 0, 400, 200)), nil); err != nil {
+		t.Fatalf("encode image: %s", err)
+	}
+	if err := jpeg.Encode(&jpegSrc, image.NewGray(image.Rect(0, 0, 400, 200)), nil); err != nil {
+		t.Fatalf("encode image: %s", err)
+	}
+	fsys := fstest.MapFS{
+		"images/text.png":      {Data: []byte("not an image")},
+		"images/animation.png": {Data: gifSrc.Bytes()},
+		"images/truncated.jpg": {Data: jpegSrc.Bytes()[:jpegSrc.Len()/2]}, // header is fine, decoding fails
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /img/{path...}", resizedImage(newImageResizer(fsys, []int{100}, nil, "", 1<<20)))
+
+	for name := range fsys {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/img/"+name+"?w=100", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("expected status 404, got %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpMailer sends plain text emails through an SMTP server.
+type smtpMailer struct {
+	addr string
+	from mail.Address
+	// auth is nil if the server doesn't require authentication.
+	auth smtp.Auth
+	now  func() time.Time
+}
+
+// newSMTPMailer creates mailer. PLAIN authentication is used if username is set,
+// net/smtp allows it only over TLS or to localhost.
+func newSMTPMailer(addr, from, username, password string) (*smtpMailer, error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("parse from address: %w", err)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("parse SMTP address: %w", err)
+	}
+
+	m := &smtpMailer{
+		addr: addr,
+		from: *fromAddr,
+		now:  time.Now,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m, nil
+}
+
+// Send sends email. If unsubscribeURL is set, it is added as one-click List-Unsubscribe, see RFC 8058.
+func (m *smtpMailer) Send(to, subject, body, unsubscribeURL string) error {
+	msg, err := m.message(to, subject, body, unsubscribeURL)
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from.Address, []string{to}, msg); err != nil {
+		return fmt.Errorf("send mail to %s: %w", to, err)
+	}
+
+	return nil
+}
+
+func (m *smtpMailer) message(to, subject, body, unsubscribeURL string) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	_, domain, _ := cutLast(m.from.Address, "@")
+
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", m.from.String())
+	header("To", to)
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", m.now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	if unsubscribeURL != "" {
+		header("List-Unsubscribe", "<"+unsubscribeURL+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b) // also converts line breaks to CRLF
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -2,45 +2,250 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/dmksnnk/blog"
+	"github.com/dmksnnk/blog/internal/related"
+	"github.com/dmksnnk/blog/internal/rss"
 )
 
 type config struct {
 	ListenAddress string `env:"LISTEN_ADDRESS" envDefault:":8080"`
+	// BaseURL is the canonical URL of the site, e.g. https://getpid.dev/.
+	// If empty, it is taken from the canonical link of the embedded index.html.
+	BaseURL string `env:"BASE_URL"`
+	// CanonicalRedirect enables redirects to the canonical scheme, host and path.
+	// It is off by default, so the server run locally is not redirected to the production site.
+	CanonicalRedirect bool `env:"CANONICAL_REDIRECT" envDefault:"false"`
+	// TracesExporter is where to export spans: none, otlp or console.
+	TracesExporter string `env:"OTEL_TRACES_EXPORTER" envDefault:"none"`
+	// OTLPEndpoint is the base URL of OTLP/HTTP collector.
+	OTLPEndpoint string     `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:"http://localhost:4318"`
+	ServiceName  string     `env:"OTEL_SERVICE_NAME" envDefault:"blog"`
+	LogLevel     slog.Level `env:"LOG_LEVEL" envDefault:"info"`
+	// AdminListenAddress is the address of the admin listener with pprof and runtime controls.
+	AdminListenAddress string `env:"ADMIN_LISTEN_ADDRESS" envDefault:"127.0.0.1:8081"`
+	// AdminToken is the bearer token for the admin listener. Admin listener is disabled if empty.
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// MaintenanceRetryAfter is the default Retry-After for maintenance mode.
+	MaintenanceRetryAfter time.Duration `env:"MAINTENANCE_RETRY_AFTER" envDefault:"5m"`
+	// SiteName is shown on Open Graph images.
+	SiteName string `env:"SITE_NAME" envDefault:"getpid.dev"`
+	// OGCacheDir is the disk cache of Open Graph images, defaults to a directory in os.TempDir().
+	OGCacheDir string `env:"OG_CACHE_DIR"`
+	// ImageWidths are widths images can be resized to, keep in sync with params.imageWidths in hugo.toml.
+	ImageWidths []int `env:"IMAGE_WIDTHS" envDefault:"320,640,960,1280,1920"`
+	// ImageQualities are allowed JPEG qualities of resized images, in addition to the default one.
+	ImageQualities []int `env:"IMAGE_QUALITIES" envDefault:"50,90"`
+	// ImageCacheDir is the disk cache of resized images, defaults to a directory in os.TempDir().
+	ImageCacheDir string `env:"IMAGE_CACHE_DIR"`
+	// ImageCacheBytes limits size of resized images cached in memory.
+	ImageCacheBytes int64 `env:"IMAGE_CACHE_BYTES" envDefault:"67108864"`
+	// PreviewDir is the site built with drafts, served under /preview/. Preview is disabled if empty.
+	PreviewDir string `env:"PREVIEW_DIR"`
+	// PreviewSecret signs preview links, required if preview is enabled.
+	PreviewSecret string `env:"PREVIEW_SECRET"`
+	// PreviewTTL is the default lifetime of preview links.
+	PreviewTTL time.Duration `env:"PREVIEW_TTL" envDefault:"72h"`
+	// PreviewMaxTTL limits lifetime of preview links.
+	PreviewMaxTTL time.Duration `env:"PREVIEW_MAX_TTL" envDefault:"720h"`
+	// PreviewRevokedFile stores revoked preview links. Revocations are lost on restart if empty.
+	PreviewRevokedFile string `env:"PREVIEW_REVOKED_FILE"`
+	// SitesConfig is a YAML file with sites served by the Host header, see sitesConfig.
+	// Only the blog is served if empty.
+	SitesConfig string `env:"SITES_CONFIG"`
+	// NewsletterSecret signs newsletter confirmation and unsubscribe links. Newsletter is disabled if empty.
+	NewsletterSecret string `env:"NEWSLETTER_SECRET"`
+	// NewsletterSubscribersFile stores subscribers, required if newsletter is enabled.
+	NewsletterSubscribersFile string `env:"NEWSLETTER_SUBSCRIBERS_FILE"`
+	// NewsletterStateFile stores posts already sent with the digest, required to send digest.
+	NewsletterStateFile string `env:"NEWSLETTER_STATE_FILE"`
+	// NewsletterConfirmTTL is how long confirmation links are valid.
+	NewsletterConfirmTTL time.Duration `env:"NEWSLETTER_CONFIRM_TTL" envDefault:"48h"`
+	// NewsletterResendAfter is how often confirmation can be sent again to a pending subscriber.
+	// Pending subscribers are removed when their confirmation link expires.
+	NewsletterResendAfter time.Duration `env:"NEWSLETTER_RESEND_AFTER" envDefault:"1h"`
+	// NewsletterSubscribeLimit is how many subscribe requests are allowed from one IP per hour, 0 disables the limit.
+	NewsletterSubscribeLimit int `env:"NEWSLETTER_SUBSCRIBE_LIMIT" envDefault:"10"`
+	// NewsletterFrom is the sender of newsletter emails.
+	NewsletterFrom string `env:"NEWSLETTER_FROM" envDefault:"getpid.dev <newsletter@getpid.dev>"`
+	// SMTPAddress is the host:port of the SMTP server newsletter is sent through.
+	SMTPAddress  string `env:"SMTP_ADDRESS" envDefault:"localhost:25"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
 }
 
 func main() {
+	prerenderOG := flag.Bool("prerender-og", false, "render Open Graph images of all posts into OG_CACHE_DIR and exit")
+	sendDigestFlag := flag.Bool("send-digest", false, "mail new posts of the embedded index.xml to newsletter subscribers and exit")
+	flag.Parse()
+
 	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	cfg := parseConfig()
 
+	var logLevel slog.LevelVar
+	logLevel.Set(cfg.LogLevel)
+	slog.SetDefault(slog.New(newTraceLogHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel}))))
+
+	tr, err := newTracerFromConfig(cfg)
+	if err != nil {
+		slog.Error("failed to create tracer", "error", err)
+		os.Exit(1)
+	}
+
 	publicFS, err := fs.Sub(blog.Public, "public")
 	if err != nil {
 		slog.Error("failed to create sub fs", "error", err)
 		os.Exit(1)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/-/health", health())
-	mux.Handle("/", GzipMiddleware(
-		CacheMiddleware(
-			http.FileServerFS(publicFS),
-		),
-	))
+	relatedIdx, err := loadRelated(blog.Related)
+	if err != nil {
+		slog.Error("failed to load related posts", "error", err)
+		os.Exit(1)
+	}
+
+	posts, err := loadPosts(publicFS)
+	if err != nil {
+		slog.Error("failed to load posts", "error", err)
+		os.Exit(1)
+	}
+	if err := loadHeadings(publicFS, posts); err != nil {
+		slog.Error("failed to load headings of posts", "error", err)
+		os.Exit(1)
+	}
+
+	ogCacheDir := cfg.OGCacheDir
+	if ogCacheDir == "" {
+		ogCacheDir = filepath.Join(os.TempDir(), "blog-og")
+	}
+	ogImgs, err := newOGImages(posts, cfg.SiteName, ogCacheDir)
+	if err != nil {
+		slog.Error("failed to create Open Graph images renderer", "error", err)
+		os.Exit(1)
+	}
+
+	if *prerenderOG {
+		if err := ogImgs.Prerender(); err != nil {
+			slog.Error("failed to prerender Open Graph images", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("prerendered Open Graph images", "posts", len(posts), "dir", ogCacheDir)
+		return
+	}
+
+	siteFeeds, err := loadFeeds(publicFS, cfg.SiteName)
+	if err != nil {
+		slog.Error("failed to load feeds", "error", err)
+		os.Exit(1)
+	}
+
+	var news *newsletter
+	if cfg.NewsletterSecret != "" {
+		news, err = newNewsletterFromConfig(cfg, publicFS)
+		if err != nil {
+			slog.Error("failed to create newsletter", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *sendDigestFlag {
+		if err := sendDigestFromConfig(cfg, publicFS, news); err != nil {
+			slog.Error("failed to send digest", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var canonical *url.URL
+	if cfg.CanonicalRedirect {
+		canonical, err = canonicalBaseURL(cfg.BaseURL, publicFS)
+		if err != nil {
+			slog.Error("failed to get canonical URL", "error", err)
+			os.Exit(1)
+		}
+
+		slog.Info("redirecting to canonical URL", "url", canonical.String())
+	}
+
+	imageCacheDir := cfg.ImageCacheDir
+	if imageCacheDir == "" {
+		imageCacheDir = filepath.Join(os.TempDir(), "blog-img")
+	}
+	resizer := newImageResizer(publicFS, cfg.ImageWidths, cfg.ImageQualities, imageCacheDir, cfg.ImageCacheBytes)
+
+	var (
+		links   *previewLinks
+		preview http.Handler
+	)
+	if cfg.PreviewDir != "" {
+		links, err = newPreviewLinksFromConfig(cfg, publicFS)
+		if err != nil {
+			slog.Error("failed to create preview links", "error", err)
+			os.Exit(1)
+		}
+		preview = newPreviewHandler(os.DirFS(cfg.PreviewDir), links, tr)
+
+		slog.Info("serving drafts preview", "dir", cfg.PreviewDir)
+	}
+
+	blogSite := &site{
+		name: "blog",
+		fsys: publicFS,
+		handler: newBlogHandler(blogDeps{
+			fsys:     publicFS,
+			related:  relatedIdx,
+			og:       ogImgs,
+			resizer:  resizer,
+			feeds:    siteFeeds,
+			taxonomy: newTaxonomy(posts),
+			preview:  preview,
+			news:     news,
+		}, canonical, tr),
+	}
+	sites := []*site{blogSite}
+	if cfg.SitesConfig != "" {
+		siteCfgs, err := loadSitesConfig(cfg.SitesConfig)
+		if err != nil {
+			slog.Error("failed to load sites config", "error", err)
+			os.Exit(1)
+		}
+		sites, err = newSites(siteCfgs, blogSite, tr)
+		if err != nil {
+			slog.Error("failed to create sites", "error", err)
+			os.Exit(1)
+		}
+
+		for _, s := range sites {
+			slog.Info("serving site", "name", s.name, "hosts", s.hosts)
+		}
+	}
+
+	var m maintenance
+	handler := newHandler(sites, tr, &m)
 
 	srv := http.Server{
 		Addr:    cfg.ListenAddress,
-		Handler: mux,
+		Handler: handler,
+	}
+
+	adminSrv := http.Server{
+		Addr:    cfg.AdminListenAddress,
+		Handler: newAdminHandler(cfg.AdminToken, &logLevel, &m, cfg.MaintenanceRetryAfter, links),
 	}
 
 	go func() {
@@ -49,6 +254,16 @@ func main() {
 			panic(err)
 		}
 	}()
+	if cfg.AdminToken != "" {
+		go func() {
+			slog.Info("starting admin server", "address", adminSrv.Addr)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				panic(err)
+			}
+		}()
+	} else {
+		slog.Warn("admin server is disabled, set ADMIN_TOKEN to enable it")
+	}
 	<-rootCtx.Done()
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -56,10 +271,82 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("failed to shutdown server", "error", err)
 	}
+	if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shutdown admin server", "error", err)
+	}
+	if err := tr.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shutdown tracer", "error", err)
+	}
 
 	slog.Info("server shutdown")
 }
 
+// newHandler creates handler chain of the public listener, which routes requests to sites by host.
+// The first site serves unknown hosts.
+func newHandler(sites []*site, tr *tracer, m *maintenance) http.Handler {
+	healthHandler := health(sites)
+	router := newHostRouter(sites)
+	// not a ServeMux, it would clean paths before canonical redirects of the sites
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/-/health" {
+			healthHandler(w, r)
+			return
+		}
+
+		router.ServeHTTP(w, r)
+	})
+
+	return TracingMiddleware(tr, MaintenanceMiddleware(m, handler))
+}
+
+// blogDeps is what the blog site serves.
+type blogDeps struct {
+	fsys     fs.FS
+	related  related.Index
+	og       *ogImages
+	resizer  *imageResizer
+	feeds    *feeds
+	taxonomy *taxonomy
+	// preview serves drafts, it is disabled if nil.
+	preview http.Handler
+	// news is disabled if nil.
+	news *newsletter
+}
+
+// newBlogHandler creates handler of the blog site, canonical redirects are disabled if canonical is nil.
+func newBlogHandler(d blogDeps, canonical *url.URL, tr *tracer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/related", relatedPosts(d.related))
+	mux.HandleFunc("GET /api/tags", listTags(d.taxonomy))
+	mux.HandleFunc("GET /api/tags/{tag}", getTag(d.taxonomy))
+	mux.HandleFunc("GET /api/series", listSeries(d.taxonomy))
+	mux.HandleFunc("GET /api/series/{series}", getSeries(d.taxonomy))
+	mux.HandleFunc("GET /api/series/{series}/outline", seriesOutline(d.taxonomy))
+	mux.HandleFunc("GET /api/navigation", navigation(d.taxonomy))
+	mux.HandleFunc("GET /api/posts", listPosts(d.taxonomy))
+	mux.Handle("GET /og/{file}", SpanMiddleware(tr, "og", http.HandlerFunc(ogImage(d.og))))
+	mux.Handle("GET /img/{path...}", SpanMiddleware(tr, "resize", http.HandlerFunc(resizedImage(d.resizer))))
+	mux.Handle("GET /feed.json", SpanMiddleware(tr, "feed", GzipMiddleware(http.HandlerFunc(serveFeed(d.feeds.json)))))
+	mux.Handle("GET /atom.xml", SpanMiddleware(tr, "feed", GzipMiddleware(http.HandlerFunc(serveFeed(d.feeds.atom)))))
+	if d.preview != nil {
+		mux.Handle(previewPrefix+"/", SpanMiddleware(tr, "preview", d.preview))
+	}
+	if d.news != nil {
+		d.news.register(mux)
+	}
+	mux.Handle("/", SpanMiddleware(tr, "gzip", GzipMiddleware(
+		SpanMiddleware(tr, "cache", CacheMiddleware(
+			SpanMiddleware(tr, "file", http.FileServerFS(d.fsys)),
+		)),
+	)))
+
+	if canonical != nil {
+		return CanonicalMiddleware(canonical, d.fsys, mux)
+	}
+
+	return mux
+}
+
 func parseConfig() config {
 	var cfg config
 	if err := env.Parse(&cfg); err != nil {
@@ -70,10 +357,93 @@ func parseConfig() config {
 	return cfg
 }
 
-func health() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"status":"ok"}`))
+func newPreviewLinksFromConfig(cfg config, publicFS fs.FS) (*previewLinks, error) {
+	if cfg.PreviewSecret == "" {
+		return nil, errors.New("PREVIEW_SECRET is required to serve drafts preview")
+	}
+
+	base, err := canonicalBaseURL(cfg.BaseURL, publicFS)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPreviewLinks(cfg.PreviewSecret, base, cfg.PreviewTTL, cfg.PreviewMaxTTL, cfg.PreviewRevokedFile)
+}
+
+func newNewsletterFromConfig(cfg config, publicFS fs.FS) (*newsletter, error) {
+	if cfg.NewsletterSubscribersFile == "" {
+		return nil, errors.New("NEWSLETTER_SUBSCRIBERS_FILE is required for newsletter")
+	}
+
+	base, err := canonicalBaseURL(cfg.BaseURL, publicFS)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := newSubscribers(cfg.NewsletterSubscribersFile, cfg.NewsletterConfirmTTL, cfg.NewsletterResendAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	mailer, err := newSMTPMailer(cfg.SMTPAddress, cfg.NewsletterFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	var limiter *ipLimiter
+	if cfg.NewsletterSubscribeLimit > 0 {
+		limiter = newIPLimiter(cfg.NewsletterSubscribeLimit, time.Hour)
+	}
+
+	return &newsletter{
+		subs:       subs,
+		tokens:     newNewsletterTokens(cfg.NewsletterSecret),
+		mailer:     mailer,
+		base:       base,
+		siteName:   cfg.SiteName,
+		confirmTTL: cfg.NewsletterConfirmTTL,
+		limiter:    limiter,
+	}, nil
+}
+
+func sendDigestFromConfig(cfg config, publicFS fs.FS, news *newsletter) error {
+	if news == nil {
+		return errors.New("NEWSLETTER_SECRET is required to send digest")
+	}
+	if cfg.NewsletterStateFile == "" {
+		return errors.New("NEWSLETTER_STATE_FILE is required to send digest")
+	}
+
+	f, err := publicFS.Open("index.xml")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ch, err := rss.Parse(f)
+	if err != nil {
+		return err
+	}
+
+	sent, err := sendDigest(ch, news, cfg.NewsletterStateFile)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("sent digest", "posts", sent, "subscribers", len(news.subs.Confirmed()))
+	return nil
+}
+
+func newTracerFromConfig(cfg config) (*tracer, error) {
+	switch cfg.TracesExporter {
+	case "none", "":
+		return newTracer(nil), nil
+	case "console":
+		return newTracer(newStdoutExporter(os.Stdout)), nil
+	case "otlp":
+		client := &http.Client{Timeout: 10 * time.Second}
+		return newTracer(newOTLPExporter(client, cfg.OTLPEndpoint, cfg.ServiceName)), nil
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", cfg.TracesExporter)
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const maintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Under maintenance</title>
+</head>
+<body>
+<h1>Under maintenance</h1>
+<p>The blog is temporarily unavailable. Please come back in a few minutes.</p>
+</body>
+</html>
+`
+
+// maintenance is a switchable maintenance mode.
+type maintenance struct {
+	enabled    atomic.Bool
+	retryAfter atomic.Int64 // seconds
+}
+
+// Enable turns maintenance mode on, clients are asked to retry after given duration.
+func (m *maintenance) Enable(retryAfter time.Duration) {
+	m.retryAfter.Store(int64(retryAfter.Seconds()))
+	m.enabled.Store(true)
+}
+
+// Disable turns maintenance mode off.
+func (m *maintenance) Disable() {
+	m.enabled.Store(false)
+}
+
+// Enabled reports whether maintenance mode is on.
+func (m *maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// RetryAfter returns how long clients should wait before retrying.
+func (m *maintenance) RetryAfter() time.Duration {
+	return time.Duration(m.retryAfter.Load()) * time.Second
+}
+
+// MaintenanceMiddleware serves 503 page while maintenance mode is on.
+// Service endpoints under /-/ keep working.
+func MaintenanceMiddleware(m *maintenance, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled() || strings.HasPrefix(r.URL.Path, "/-/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(m.RetryAfter().Seconds()), 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write([]byte(maintenancePage))
+		}
+	})
+}
@@ -9,7 +9,8 @@ import (
 
 func GzipMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// Content-Range of partial responses refers to the uncompressed content, don't compress them
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || r.Header.Get("Range") != "" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -32,8 +33,8 @@ func GzipMiddleware(next http.Handler) http.Handler {
 
 func CacheMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if the request is cacheable
-		if r.Method != http.MethodGet {
+		// Check if the request is cacheable, cache rules of the site take precedence
+		if r.Method != http.MethodGet || w.Header().Get("Cache-Control") != "" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -67,12 +68,32 @@ func newGzipResponseWriter(rw http.ResponseWriter) *gzipResponseWriter {
 type gzipResponseWriter struct {
 	http.ResponseWriter
 	w *gzip.Writer
+	// plain is set when the next handler drops Content-Encoding, e.g. http.Error does it.
+	plain bool
 }
 
-func (rw gzipResponseWriter) Write(b []byte) (int, error) {
+// WriteHeader removes Content-Length set by the next handler, since compressed content length is different.
+func (rw *gzipResponseWriter) WriteHeader(code int) {
+	if rw.Header().Get("Content-Encoding") != "gzip" {
+		rw.plain = true
+	} else {
+		rw.Header().Del("Content-Length")
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if rw.plain {
+		return rw.ResponseWriter.Write(b)
+	}
+
 	return rw.w.Write(b)
 }
 
-func (rw gzipResponseWriter) Close() error {
+func (rw *gzipResponseWriter) Close() error {
+	if rw.plain {
+		return nil
+	}
+
 	return rw.w.Close()
 }
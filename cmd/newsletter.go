@@ -0,0 +1,441 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	newsletterPrefix = "/api/newsletter"
+
+	tokenConfirm     = "confirm"
+	tokenUnsubscribe = "unsubscribe"
+)
+
+var (
+	errNewsletterTokenInvalid = errors.New("link is invalid")
+	errNewsletterTokenExpired = errors.New("link has expired")
+)
+
+// subscriber is a newsletter subscriber, pending until the email is confirmed.
+type subscriber struct {
+	Email              string    `json:"email"`
+	Confirmed          bool      `json:"confirmed"`
+	CreatedAt          time.Time `json:"created_at"`
+	ConfirmedAt        time.Time `json:"confirmed_at,omitzero"`
+	ConfirmationSentAt time.Time `json:"confirmation_sent_at,omitzero"`
+}
+
+// sentAt returns when the last confirmation email was sent, or when subscriber was added if none was sent.
+func (s subscriber) sentAt() time.Time {
+	if s.ConfirmationSentAt.IsZero() {
+		return s.CreatedAt
+	}
+	return s.ConfirmationSentAt
+}
+
+// subscribers is the subscriber store, it is kept in memory and written to the JSON file on every change.
+type subscribers struct {
+	file        string
+	pendingTTL  time.Duration // pending subscribers are removed after it
+	resendAfter time.Duration // confirmation is sent again to a pending subscriber only after it
+
+	mu   sync.Mutex
+	subs map[string]subscriber // by lower case email
+}
+
+func newSubscribers(file string, pendingTTL, resendAfter time.Duration) (*subscribers, error) {
+	s := &subscribers{
+		file:        file,
+		pendingTTL:  pendingTTL,
+		resendAfter: resendAfter,
+		subs:        make(map[string]subscriber),
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read subscribers: %w", err)
+	}
+
+	var list []subscriber
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decode subscribers: %w", err)
+	}
+	for _, sub := range list {
+		s.subs[strings.ToLower(sub.Email)] = sub
+	}
+
+	return s, nil
+}
+
+// Add adds pending subscriber and reports whether the confirmation email should be sent.
+// It returns false if the email is already confirmed or the confirmation was sent less than resendAfter ago,
+// so re-subscribing can't be used to flood someone's inbox. Record the sent email with Sent.
+// Expired pending subscribers are removed.
+func (s *subscribers) Add(email string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(email)
+	sub, ok := s.subs[key]
+	if ok && (sub.Confirmed || !sub.ConfirmationSentAt.IsZero() && now.Sub(sub.ConfirmationSentAt) < s.resendAfter) {
+		return false, nil
+	}
+	if !ok {
+		s.subs[key] = subscriber{Email: email, CreatedAt: now}
+	}
+
+	s.expire(now)
+	return true, s.save()
+}
+
+// Sent records that the confirmation email was sent to the pending subscriber.
+func (s *subscribers) Sent(email string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(email)
+	sub, ok := s.subs[key]
+	if !ok || sub.Confirmed {
+		return nil
+	}
+
+	sub.ConfirmationSentAt = now
+	s.subs[key] = sub
+	return s.save()
+}
+
+// expire removes pending subscribers, whose last confirmation was sent more than pendingTTL ago.
+func (s *subscribers) expire(now time.Time) {
+	for key, sub := range s.subs {
+		if !sub.Confirmed && now.Sub(sub.sentAt()) >= s.pendingTTL {
+			delete(s.subs, key)
+		}
+	}
+}
+
+// Confirm confirms subscriber, adding it if it was removed after the confirmation email was sent.
+func (s *subscribers) Confirm(email string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(email)
+	sub, ok := s.subs[key]
+	if ok && sub.Confirmed {
+		return nil
+	}
+	if !ok {
+		sub = subscriber{Email: email, CreatedAt: now}
+	}
+
+	sub.Confirmed = true
+	sub.ConfirmedAt = now
+	s.subs[key] = sub
+	return s.save()
+}
+
+// Remove removes subscriber, it is a no-op for unknown emails.
+func (s *subscribers) Remove(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(email)
+	if _, ok := s.subs[key]; !ok {
+		return nil
+	}
+
+	delete(s.subs, key)
+	return s.save()
+}
+
+// Get returns subscriber by email.
+func (s *subscribers) Get(email string) (subscriber, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[strings.ToLower(email)]
+	return sub, ok
+}
+
+// Confirmed returns emails of confirmed subscribers, sorted.
+func (s *subscribers) Confirmed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var emails []string
+	for _, sub := range s.subs {
+		if sub.Confirmed {
+			emails = append(emails, sub.Email)
+		}
+	}
+	slices.Sort(emails)
+
+	return emails
+}
+
+func (s *subscribers) save() error {
+	list := make([]subscriber, 0, len(s.subs))
+	for _, sub := range s.subs {
+		list = append(list, sub)
+	}
+	slices.SortFunc(list, func(a, b subscriber) int { return strings.Compare(a.Email, b.Email) })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Dir(s.file), filepath.Base(s.file), data)
+}
+
+// newsletterTokens signs emails for confirmation and unsubscribe links.
+// Token is "<base64 email>.<expiry unix>.<signature>", where signature is HMAC-SHA256 of the purpose, email and expiry,
+// so a confirmation token can't be used to unsubscribe and vice versa. Zero expiry means the token never expires.
+type newsletterTokens struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func newNewsletterTokens(secret string) *newsletterTokens {
+	return &newsletterTokens{secret: []byte(secret), now: time.Now}
+}
+
+// Sign creates token for the email, it never expires if ttl is 0.
+func (t *newsletterTokens) Sign(purpose, email string, ttl time.Duration) string {
+	var exp int64
+	if ttl > 0 {
+		exp = t.now().Add(ttl).Unix()
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(email)) + "." + strconv.FormatInt(exp, 10)
+
+	return payload + "." + t.signature(purpose, payload)
+}
+
+// Verify checks token signature and expiry and returns the signed email.
+func (t *newsletterTokens) Verify(purpose, token string) (string, error) {
+	payload, sig, ok := cutLast(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(t.signature(purpose, payload))) {
+		return "", errNewsletterTokenInvalid
+	}
+
+	enc, exp, _ := strings.Cut(payload, ".")
+	email, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", errNewsletterTokenInvalid
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", errNewsletterTokenInvalid
+	}
+	if unix != 0 && !t.now().Before(time.Unix(unix, 0)) {
+		return "", errNewsletterTokenExpired
+	}
+
+	return string(email), nil
+}
+
+func (t *newsletterTokens) signature(purpose, payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(purpose + "\x00" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newsletter handles subscriptions with double opt-in: subscribers are pending until they follow the link
+// from the confirmation email.
+type newsletter struct {
+	subs       *subscribers
+	tokens     *newsletterTokens
+	mailer     *smtpMailer
+	base       *url.URL
+	siteName   string
+	confirmTTL time.Duration
+	limiter    *ipLimiter // limits subscribe requests, nil means no limit
+}
+
+func (n *newsletter) link(name, token string) string {
+	u := n.base.JoinPath(newsletterPrefix, name)
+	u.RawQuery = url.Values{"token": {token}}.Encode()
+	return u.String()
+}
+
+// UnsubscribeURL returns link which unsubscribes the email, it never expires.
+func (n *newsletter) UnsubscribeURL(email string) string {
+	return n.link("unsubscribe", n.tokens.Sign(tokenUnsubscribe, email, 0))
+}
+
+func (n *newsletter) sendConfirmation(email string) error {
+	body := fmt.Sprintf(`Hi,
+
+someone, hopefully you, subscribed %s to new posts on %s.
+Confirm the subscription by following the link:
+
+%s
+
+The link expires in %s. If you didn't subscribe, ignore this email.
+`, email, n.siteName, n.link("confirm", n.tokens.Sign(tokenConfirm, email, n.confirmTTL)), n.confirmTTL)
+
+	return n.mailer.Send(email, "Confirm subscription to "+n.siteName, body, "")
+}
+
+// register registers newsletter endpoints on mux.
+func (n *newsletter) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+newsletterPrefix+"/subscribe", subscribe(n))
+	mux.HandleFunc("GET "+newsletterPrefix+"/confirm", confirmSubscription(n))
+	mux.HandleFunc("GET "+newsletterPrefix+"/unsubscribe", unsubscribePage(n))
+	mux.HandleFunc("POST "+newsletterPrefix+"/unsubscribe", unsubscribe(n))
+}
+
+type subscribeRequest struct {
+	Email string `json:"email"`
+}
+
+// subscribe adds pending subscriber and sends confirmation email. Email is from JSON body or "email" form field.
+// Response is the same for already confirmed emails, so it doesn't tell who is subscribed.
+func subscribe(n *newsletter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if n.limiter != nil {
+			if ok, retry := n.limiter.Allow(clientIP(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		var req subscribeRequest
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+		} else {
+			r.Body = http.MaxBytesReader(w, r.Body, 1<<10)
+			req.Email = r.PostFormValue("email")
+		}
+
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil || addr.Address != strings.TrimSpace(req.Email) {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+
+		added, err := n.subs.Add(addr.Address, n.tokens.now())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to add subscriber", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if added {
+			if err := n.sendConfirmation(addr.Address); err != nil {
+				slog.ErrorContext(r.Context(), "failed to send confirmation email", "error", err)
+				http.Error(w, "Failed to send confirmation email", http.StatusBadGateway)
+				return
+			}
+			if err := n.subs.Sent(addr.Address, n.tokens.now()); err != nil {
+				slog.ErrorContext(r.Context(), "failed to record confirmation email", "error", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "confirmation sent"})
+	}
+}
+
+func confirmSubscription(n *newsletter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email, err := n.tokens.Verify(tokenConfirm, r.URL.Query().Get("token"))
+		if err != nil {
+			writeNewsletterPage(w, http.StatusForbidden, "Subscription is not confirmed", "The "+err.Error()+", please subscribe again.", "")
+			return
+		}
+
+		if err := n.subs.Confirm(email, n.tokens.now()); err != nil {
+			slog.ErrorContext(r.Context(), "failed to confirm subscriber", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "newsletter subscription confirmed")
+		writeNewsletterPage(w, http.StatusOK, "Subscription confirmed", "You will get an email when new posts are published.", "")
+	}
+}
+
+// unsubscribePage asks to confirm unsubscribing, since mail scanners follow links in emails.
+func unsubscribePage(n *newsletter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if _, err := n.tokens.Verify(tokenUnsubscribe, token); err != nil {
+			writeNewsletterPage(w, http.StatusForbidden, "Unsubscribe", "The "+err.Error()+".", "")
+			return
+		}
+
+		writeNewsletterPage(w, http.StatusOK, "Unsubscribe", "Stop getting emails about new posts?", token)
+	}
+}
+
+// unsubscribe removes subscriber, it is also the one-click List-Unsubscribe endpoint.
+func unsubscribe(n *newsletter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email, err := n.tokens.Verify(tokenUnsubscribe, r.URL.Query().Get("token"))
+		if err != nil {
+			writeNewsletterPage(w, http.StatusForbidden, "Unsubscribe", "The "+err.Error()+".", "")
+			return
+		}
+
+		if err := n.subs.Remove(email); err != nil {
+			slog.ErrorContext(r.Context(), "failed to remove subscriber", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "newsletter unsubscribed")
+		writeNewsletterPage(w, http.StatusOK, "Unsubscribed", "You will not get emails anymore.", "")
+	}
+}
+
+var newsletterPage = template.Must(template.New("newsletter").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{- if .Token}}
+<form method="post" action="?token={{.Token}}">
+<button type="submit">Unsubscribe</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeNewsletterPage writes a page with a message, with unsubscribe button if token is set.
+func writeNewsletterPage(w http.ResponseWriter, status int, title, message, token string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = newsletterPage.Execute(w, struct{ Title, Message, Token string }{title, message, token})
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewsletterTokens(t *testing.T) {
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	tokens := newNewsletterTokens("secret")
+	tokens.now = func() time.Time { return now }
+
+	confirm := tokens.Sign(tokenConfirm, "reader@example.com", time.Hour)
+	email, err := tokens.Verify(tokenConfirm, confirm)
+	if err != nil {
+		t.Fatalf("verify: %s", err)
+	}
+	if email != "reader@example.com" {
+		t.Fatalf("expected reader@example.com, got %s", email)
+	}
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+		want    error
+	}{
+		{name: "other purpose", purpose: tokenUnsubscribe, token: confirm, want: errNewsletterTokenInvalid},
+		{name: "other email", purpose: tokenConfirm, token: "b3RoZXJAZXhhbXBsZS5jb20" + confirm[strings.Index(confirm, "."):], want: errNewsletterTokenInvalid},
+		{name: "garbage", purpose: tokenConfirm, token: "garbage", want: errNewsletterTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tokens.Verify(tt.purpose, tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	unsubscribe := tokens.Sign(tokenUnsubscribe, "reader@example.com", 0)
+	tokens.now = func() time.Time { return now.Add(365 * 24 * time.Hour) }
+	if _, err := tokens.Verify(tokenConfirm, confirm); !errors.Is(err, errNewsletterTokenExpired) {
+		t.Fatalf("expected expired error, got %v", err)
+	}
+	if _, err := tokens.Verify(tokenUnsubscribe, unsubscribe); err != nil {
+		t.Fatalf("expected unsubscribe token to never expire, got %v", err)
+	}
+}
+
+func TestNewsletter(t *testing.T) {
+	stub := newSMTPStub(t)
+	n := newTestNewsletter(t, stub.Addr())
+
+	mux := http.NewServeMux()
+	n.register(mux)
+
+	do := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/newsletter/subscribe", "application/json", `{"email":"not an email"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid email, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/newsletter/subscribe", "application/x-www-form-urlencoded", "email="+url.QueryEscape("Reader <reader@example.com>")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for email with name, got %d", rec.Code)
+	}
+
+	rec := do(http.MethodPost, "/api/newsletter/subscribe", "application/json", `{"email":"reader@example.com"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body)
+	}
+	if sub, ok := n.subs.Get("reader@example.com"); !ok || sub.Confirmed {
+		t.Fatalf("expected pending subscriber, got %+v", sub)
+	}
+
+	messages := stub.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "reader@example.com" {
+		t.Fatalf("expected confirmation email, got %v", messages)
+	}
+	confirmLink := findLink(t, messages[0].Body(t), "/api/newsletter/confirm")
+
+	rec = do(http.MethodGet, confirmLink, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on confirm, got %d: %s", rec.Code, rec.Body)
+	}
+
+	subs, err := newSubscribers(n.subs.file, time.Hour, time.Minute) // confirmation is persisted
+	if err != nil {
+		t.Fatalf("load subscribers: %s", err)
+	}
+	if sub, ok := subs.Get("reader@example.com"); !ok || !sub.Confirmed {
+		t.Fatalf("expected confirmed subscriber, got %+v", sub)
+	}
+
+	// subscribing again doesn't send confirmation and doesn't tell it is subscribed
+	rec = do(http.MethodPost, "/api/newsletter/subscribe", "application/x-www-form-urlencoded", "email=reader%40example.com")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+	if got := len(stub.Messages()); got != 1 {
+		t.Fatalf("expected no new emails, got %d", got)
+	}
+
+	unsubscribeURL, err := url.Parse(n.UnsubscribeURL("reader@example.com"))
+	if err != nil {
+		t.Fatalf("parse unsubscribe URL: %s", err)
+	}
+	confirmURL, _ := url.Parse(confirmLink)
+	if rec := do(http.MethodPost, "/api/newsletter/unsubscribe?"+confirmURL.RawQuery, "", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for confirmation token, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodGet, unsubscribeURL.RequestURI(), "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post"`) {
+		t.Fatalf("expected unsubscribe form, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := n.subs.Get("reader@example.com"); !ok {
+		t.Fatal("expected GET not to unsubscribe")
+	}
+
+	rec = do(http.MethodPost, unsubscribeURL.RequestURI(), "application/x-www-form-urlencoded", "List-Unsubscribe=One-Click")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on unsubscribe, got %d", rec.Code)
+	}
+	if _, ok := n.subs.Get("reader@example.com"); ok {
+		t.Fatal("expected subscriber to be removed")
+	}
+}
+
+func newTestNewsletter(t *testing.T, smtpAddr string) *newsletter {
+	t.Helper()
+
+	subs, err := newSubscribers(filepath.Join(t.TempDir(), "subscribers.json"), time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("create subscribers: %s", err)
+	}
+	mailer, err := newSMTPMailer(smtpAddr, "getpid.dev <newsletter@getpid.dev>", "", "")
+	if err != nil {
+		t.Fatalf("create mailer: %s", err)
+	}
+	base, _ := url.Parse("https://getpid.dev/")
+
+	return &newsletter{
+		subs:       subs,
+		tokens:     newNewsletterTokens("secret"),
+		mailer:     mailer,
+		base:       base,
+		siteName:   "getpid.dev",
+		confirmTTL: time.Hour,
+	}
+}
+
+func TestSubscribersAdd(t *testing.T) {
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	subs, err := newSubscribers(filepath.Join(t.TempDir(), "subscribers.json"), time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("create subscribers: %s", err)
+	}
+
+	add := func(email string, at time.Time) bool {
+		t.Helper()
+
+		send, err := subs.Add(email, at)
+		if err != nil {
+			t.Fatalf("add subscriber: %s", err)
+		}
+		if send {
+			if err := subs.Sent(email, at); err != nil {
+				t.Fatalf("record sent confirmation: %s", err)
+			}
+		}
+		return send
+	}
+
+	if send, err := subs.Add("reader@example.com", now.Add(-time.Minute)); err != nil || !send {
+		t.Fatalf("expected confirmation for new subscriber, got %t, %v", send, err)
+	}
+	// confirmation was not sent, so it can be sent right away
+	if !add("reader@example.com", now) {
+		t.Fatal("expected confirmation for subscriber without sent confirmation")
+	}
+	if add("Reader@example.com", now.Add(5*time.Minute)) {
+		t.Fatal("expected no confirmation within resend interval")
+	}
+	if !add("reader@example.com", now.Add(10*time.Minute)) {
+		t.Fatal("expected confirmation after resend interval")
+	}
+	sub, _ := subs.Get("reader@example.com")
+	if !sub.CreatedAt.Equal(now.Add(-time.Minute)) || !sub.ConfirmationSentAt.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("expected created at %s and sent at %s, got %+v", now.Add(-time.Minute), now.Add(10*time.Minute), sub)
+	}
+
+	if err := subs.Confirm("confirmed@example.com", now); err != nil {
+		t.Fatalf("confirm subscriber: %s", err)
+	}
+	add("other@example.com", now.Add(2*time.Hour)) // expires pending subscribers
+
+	loaded, err := newSubscribers(subs.file, time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("load subscribers: %s", err)
+	}
+	if _, ok := loaded.Get("reader@example.com"); ok {
+		t.Error("expected expired pending subscriber to be removed")
+	}
+	if _, ok := loaded.Get("confirmed@example.com"); !ok {
+		t.Error("expected confirmed subscriber to be kept")
+	}
+	if _, ok := loaded.Get("other@example.com"); !ok {
+		t.Error("expected new pending subscriber to be kept")
+	}
+}
+
+func TestNewsletterSubscribeSendFailure(t *testing.T) {
+	stub := newSMTPStub(t)
+	n := newTestNewsletter(t, stub.Addr())
+
+	mux := http.NewServeMux()
+	n.register(mux)
+
+	subscribe := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/newsletter/subscribe", strings.NewReader(`{"email":"reader@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	stub.fail.Store(true)
+	if rec := subscribe(); rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502 when sending fails, got %d: %s", rec.Code, rec.Body)
+	}
+	if sub, _ := n.subs.Get("reader@example.com"); !sub.ConfirmationSentAt.IsZero() {
+		t.Fatalf("expected confirmation not to be recorded as sent, got %+v", sub)
+	}
+
+	stub.fail.Store(false)
+	if rec := subscribe(); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202 on retry, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := len(stub.Messages()); got != 1 {
+		t.Fatalf("expected confirmation email on retry, got %d emails", got)
+	}
+}
+
+func TestNewsletterSubscribeLimit(t *testing.T) {
+	stub := newSMTPStub(t)
+	n := newTestNewsletter(t, stub.Addr())
+	n.limiter = newIPLimiter(2, time.Hour)
+
+	mux := http.NewServeMux()
+	n.register(mux)
+
+	subscribe := func(email, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/newsletter/subscribe", strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if rec := subscribe(email, ""); rec.Code != http.StatusAccepted {
+			t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body)
+		}
+	}
+	rec := subscribe("c@example.com", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3600" {
+		t.Errorf("expected Retry-After 3600, got %q", got)
+	}
+	if rec := subscribe("c@example.com", "198.51.100.7, 203.0.113.9"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202 for other client, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := len(stub.Messages()); got != 3 {
+		t.Fatalf("expected 3 confirmation emails, got %d", got)
+	}
+}
+
+var linkRe = regexp.MustCompile(`https://\S+`)
+
+// findLink finds link with the path in text and returns it without scheme and host.
+func findLink(t *testing.T, text, linkPath string) string {
+	t.Helper()
+
+	for _, link := range linkRe.FindAllString(text, -1) {
+		u, err := url.Parse(link)
+		if err == nil && u.Path == linkPath {
+			return u.RequestURI()
+		}
+	}
+
+	t.Fatalf("no %s link in:\n%s", linkPath, text)
+	return ""
+}
+
+// smtpStub is an in-process SMTP server, which accepts all messages without authentication.
+type smtpStub struct {
+	ln   net.Listener
+	fail atomic.Bool // reject messages
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Header returns header of the message.
+func (m smtpMessage) Header(t *testing.T) mail.Header {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		t.Fatalf("read message: %s", err)
+	}
+
+	return msg.Header
+}
+
+// Body returns decoded body of the message.
+func (m smtpMessage) Body(t *testing.T) string {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		t.Fatalf("read message: %s", err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("read body: %s", err)
+	}
+
+	return string(body)
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStub{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStub) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *smtpStub) Messages() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	_ = tp.PrintfLine("220 localhost SMTP stub")
+	var msg smtpMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 localhost")
+		case "MAIL":
+			msg = smtpMessage{From: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			if s.fail.Load() {
+				_ = tp.PrintfLine("554 Transaction failed")
+				continue
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/og"
+)
+
+// ogImages renders Open Graph images of posts and caches them in memory and on disk by card key,
+// so changed posts get new images and unchanged ones are rendered once.
+type ogImages struct {
+	renderer *og.Renderer
+	siteName string
+	posts    map[string]post // by slug, slugs are unique, see tools/lint-content
+	// dir is the disk cache directory, disk cache is disabled if empty.
+	dir string
+	// flight renders an image once for concurrent requests of it.
+	flight singleflight[[]byte]
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+func newOGImages(posts []post, siteName, dir string) (*ogImages, error) {
+	renderer, err := og.NewRenderer()
+	if err != nil {
+		return nil, err
+	}
+
+	bySlug := make(map[string]post, len(posts))
+	for _, p := range posts {
+		bySlug[p.slugOrName()] = p
+	}
+
+	return &ogImages{
+		renderer: renderer,
+		siteName: siteName,
+		posts:    bySlug,
+		dir:      dir,
+		cache:    make(map[string][]byte),
+	}, nil
+}
+
+func (o *ogImages) card(p post) og.Card {
+	return og.Card{
+		Title:    p.Title,
+		Tags:     p.Tags,
+		Date:     p.Date,
+		SiteName: o.siteName,
+	}
+}
+
+// Image returns PNG image of the post with the slug, and its key.
+func (o *ogImages) Image(slug string) (string, []byte, bool, error) {
+	p, ok := o.posts[slug]
+	if !ok {
+		return "", nil, false, nil
+	}
+
+	card := o.card(p)
+	key := card.Key()
+
+	o.mu.Lock()
+	data, ok := o.cache[key]
+	o.mu.Unlock()
+	if ok {
+		return key, data, true, nil
+	}
+
+	data, err := o.flight.Do(key, func() ([]byte, error) {
+		return o.load(key, card)
+	})
+	if err != nil {
+		return "", nil, false, fmt.Errorf("render %s: %w", slug, err)
+	}
+
+	return key, data, true, nil
+}
+
+// load reads image of the card from the disk cache or renders it, and caches it.
+func (o *ogImages) load(key string, card og.Card) ([]byte, error) {
+	if o.dir != "" {
+		data, err := os.ReadFile(o.path(key))
+		if err == nil {
+			o.add(key, data)
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to read cached Open Graph image", "key", key, "error", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := o.renderer.Render(&buf, card); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	o.add(key, data)
+
+	if o.dir != "" {
+		if err := writeFileAtomic(o.dir, key+".png", data); err != nil { // still can serve it from memory
+			slog.Warn("failed to cache Open Graph image on disk", "key", key, "error", err)
+		}
+	}
+
+	return data, nil
+}
+
+func (o *ogImages) add(key string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.cache[key] = data
+}
+
+// Prerender renders images of all posts into the disk cache.
+func (o *ogImages) Prerender() error {
+	if o.dir == "" {
+		return errors.New("disk cache directory is not set")
+	}
+
+	for _, p := range o.posts {
+		if _, _, _, err := o.Image(p.slugOrName()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *ogImages) path(key string) string {
+	return filepath.Join(o.dir, key+".png")
+}
+
+// writeFileAtomic writes file in dir atomically, so concurrent instances sharing the directory don't read partial files.
+func writeFileAtomic(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after rename
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(dir, name))
+}
+
+// ogImage serves Open Graph image of a post, /og/<slug>.png.
+func ogImage(o *ogImages) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug, ok := strings.CutSuffix(r.PathValue("file"), ".png")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		key, data, ok, err := o.Image(slug)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to render Open Graph image", "slug", slug, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("ETag", `"`+key+`"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}
+}
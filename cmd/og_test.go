@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOGImage(t *testing.T) {
+	posts := []post{
+		{Title: "Golden Tests", Permalink: "https://getpid.dev/blog/golden-tests/", Slug: "golden-tests", Tags: []string{"Go"}, Date: time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC)},
+		{Title: "Test smell", Permalink: "https://getpid.dev/blog/test-smell/"},
+	}
+	dir := t.TempDir()
+
+	ogImgs, err := newOGImages(posts, "getpid.dev", dir)
+	if err != nil {
+		t.Fatalf("create Open Graph images: %s", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /og/{file}", ogImage(ogImgs))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/og/golden-tests.png", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	etag := rec.Header().Get("ETag")
+	key := ogImgs.card(posts[0]).Key()
+	if etag != `"`+key+`"` {
+		t.Errorf("expected ETag with card key %s, got %s", key, etag)
+	}
+
+	cached, err := os.ReadFile(filepath.Join(dir, key+".png"))
+	if err != nil {
+		t.Fatalf("expected image cached on disk: %s", err)
+	}
+	if string(cached) != rec.Body.String() {
+		t.Errorf("expected cached image to be the same as served")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/og/golden-tests.png", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", rec.Code)
+	}
+
+	// slug is taken from permalink if not set
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/og/test-smell.png", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+
+	for _, p := range []string{"/og/missing.png", "/og/golden-tests.jpg", "/og/blog/golden-tests.png"} {
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", p, rec.Code)
+		}
+	}
+}
+
+func TestOGImagePrerender(t *testing.T) {
+	posts := []post{
+		{Title: "Golden Tests", Slug: "golden-tests"},
+		{Title: "Test smell", Slug: "test-smell"},
+	}
+	dir := t.TempDir()
+
+	ogImgs, err := newOGImages(posts, "getpid.dev", dir)
+	if err != nil {
+		t.Fatalf("create Open Graph images: %s", err)
+	}
+	if err := ogImgs.Prerender(); err != nil {
+		t.Fatalf("prerender: %s", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		t.Fatalf("glob: %s", err)
+	}
+	if len(files) != len(posts) {
+		t.Fatalf("expected %d images, got %v", len(posts), files)
+	}
+
+	// new instance, e.g. after restart, reads images from disk
+	if err := os.WriteFile(files[0], []byte("cached"), 0o644); err != nil {
+		t.Fatalf("write file: %s", err)
+	}
+	ogImgs, err = newOGImages(posts, "getpid.dev", dir)
+	if err != nil {
+		t.Fatalf("create Open Graph images: %s", err)
+	}
+
+	var fromDisk bool
+	for _, p := range posts {
+		_, data, _, err := ogImgs.Image(p.Slug)
+		if err != nil {
+			t.Fatalf("image: %s", err)
+		}
+		fromDisk = fromDisk || string(data) == "cached"
+	}
+	if !fromDisk {
+		t.Errorf("expected image to be read from disk cache")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"time"
+)
+
+// post is an entry of the search index generated by Hugo, see layouts/_default/index.json.
+type post struct {
+	Title     string    `json:"title"`
+	Permalink string    `json:"permalink"`
+	Summary   string    `json:"summary"`
+	Slug      string    `json:"slug"`
+	Date      time.Time `json:"date"`
+	Tags      []string  `json:"tags"`
+	Series    []string  `json:"series"`
+	Section   string    `json:"section"`
+	Weight    int       `json:"weight"`
+	// Content is the plain text of the post.
+	Content string `json:"content"`
+	// Headings are set by loadHeadings.
+	Headings []heading `json:"-"`
+}
+
+// slugOrName returns slug of the post, or the last element of its path, as Hugo does for pages without slug.
+func (p post) slugOrName() string {
+	if p.Slug != "" {
+		return p.Slug
+	}
+
+	u, err := url.Parse(p.Permalink)
+	if err != nil {
+		return ""
+	}
+
+	return path.Base(u.Path)
+}
+
+// path returns URL path of the post, e.g. /blog/golden-tests/.
+func (p post) path() string {
+	u, err := url.Parse(p.Permalink)
+	if err != nil {
+		return p.Permalink
+	}
+
+	return u.Path
+}
+
+// loadPosts reads posts from index.json of the site.
+func loadPosts(fsys fs.FS) ([]post, error) {
+	data, err := fs.ReadFile(fsys, "index.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []post
+	if err := json.Unmarshal(data, &posts); err != nil {
+		return nil, fmt.Errorf("decode index.json: %w", err)
+	}
+
+	return posts, nil
+}
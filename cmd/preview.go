@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	previewPrefix = "/preview"
+	// previewCookie keeps preview token after following a link, so pages, styles and images load without it.
+	previewCookie = "preview_token"
+)
+
+var (
+	errPreviewTokenInvalid = errors.New("preview link is invalid")
+	errPreviewTokenExpired = errors.New("preview link has expired")
+	errPreviewTokenRevoked = errors.New("preview link has been revoked")
+)
+
+// previewLinks signs and verifies preview links. Token is "<id>.<expiry unix>.<signature>",
+// where signature is HMAC-SHA256 of id and expiry, so each link has its own expiry and can be revoked by id.
+type previewLinks struct {
+	secret []byte
+	// base is the site URL used in created links, links are relative if it is nil.
+	base       *url.URL
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	now        func() time.Time
+	// file stores revoked link ids, one per line, so revocations survive restarts. Optional.
+	file string
+
+	mu      sync.Mutex
+	revoked map[string]bool
+}
+
+func newPreviewLinks(secret string, base *url.URL, defaultTTL, maxTTL time.Duration, file string) (*previewLinks, error) {
+	p := &previewLinks{
+		secret:     []byte(secret),
+		base:       base,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+		now:        time.Now,
+		file:       file,
+		revoked:    make(map[string]bool),
+	}
+
+	if file == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open revoked preview links: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if id := strings.TrimSpace(s.Text()); id != "" {
+			p.revoked[id] = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read revoked preview links: %w", err)
+	}
+
+	return p, nil
+}
+
+// Sign creates token valid for ttl.
+func (p *previewLinks) Sign(ttl time.Duration) (token, id string, expires time.Time, err error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	id = hex.EncodeToString(b)
+	expires = p.now().Add(ttl).Truncate(time.Second)
+	payload := id + "." + strconv.FormatInt(expires.Unix(), 10)
+
+	return payload + "." + p.signature(payload), id, expires, nil
+}
+
+// Verify checks token signature, expiry and revocation.
+func (p *previewLinks) Verify(token string) (id string, expires time.Time, err error) {
+	payload, sig, ok := cutLast(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(p.signature(payload))) {
+		return "", time.Time{}, errPreviewTokenInvalid
+	}
+
+	id, exp, _ := strings.Cut(payload, ".")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", time.Time{}, errPreviewTokenInvalid
+	}
+
+	expires = time.Unix(unix, 0)
+	if !p.now().Before(expires) {
+		return "", time.Time{}, errPreviewTokenExpired
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.revoked[id] {
+		return "", time.Time{}, errPreviewTokenRevoked
+	}
+
+	return id, expires, nil
+}
+
+// Revoke revokes all links with id.
+func (p *previewLinks) Revoke(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.revoked[id] {
+		return nil
+	}
+
+	if p.file != "" {
+		f, err := os.OpenFile(p.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(f, id); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	p.revoked[id] = true
+	return nil
+}
+
+func (p *previewLinks) signature(payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns preview link of the page path with token.
+func (p *previewLinks) URL(pagePath, token string) string {
+	u := url.URL{
+		Path:     previewPrefix + "/" + strings.TrimPrefix(pagePath, "/"),
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	}
+	if p.base != nil {
+		u.Scheme = p.base.Scheme
+		u.Host = p.base.Host
+	}
+
+	return u.String()
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+// PreviewMiddleware allows only requests with valid preview token, from "token" query parameter or cookie.
+// Token from the query is moved to the cookie with a redirect, so it doesn't stay in the address bar and referrers.
+// Responses are not indexed and not stored.
+func PreviewMiddleware(links *previewLinks, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if token := r.URL.Query().Get("token"); token != "" {
+			_, expires, err := links.Verify(token)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     previewCookie,
+				Value:    token,
+				Path:     previewPrefix + "/",
+				Expires:  expires,
+				HttpOnly: true,
+				Secure:   requestScheme(r, "http") == "https",
+				SameSite: http.SameSiteLaxMode,
+			})
+
+			u := *r.URL
+			query := u.Query()
+			query.Del("token")
+			u.RawQuery = query.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+			return
+		}
+
+		cookie, err := r.Cookie(previewCookie)
+		if err != nil {
+			http.Error(w, "preview link is required", http.StatusUnauthorized)
+			return
+		}
+		if _, _, err := links.Verify(cookie.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newPreviewHandler serves site built with drafts under /preview/.
+func newPreviewHandler(previewFS fs.FS, links *previewLinks, tr *tracer) http.Handler {
+	return PreviewMiddleware(links, http.StripPrefix(previewPrefix,
+		SpanMiddleware(tr, "gzip", GzipMiddleware(
+			SpanMiddleware(tr, "file", http.FileServerFS(previewFS)),
+		)),
+	))
+}
+
+type previewLinkRequest struct {
+	// Path of the page, e.g. /blog/draft/.
+	Path string `json:"path"`
+	// TTL is in seconds.
+	TTL int64 `json:"ttl"`
+}
+
+type previewLinkResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func createPreviewLink(links *previewLinks) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req previewLinkRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ttl := links.defaultTTL
+		if req.TTL > 0 {
+			ttl = time.Duration(req.TTL) * time.Second
+		}
+		if ttl > links.maxTTL {
+			http.Error(w, fmt.Sprintf("ttl must not exceed %d seconds", int64(links.maxTTL.Seconds())), http.StatusBadRequest)
+			return
+		}
+
+		token, id, expires, err := links.Sign(ttl)
+		if err != nil {
+			slog.Error("failed to sign preview link", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("created preview link", "id", id, "path", req.Path, "expires_at", expires)
+		writeJSON(w, http.StatusCreated, previewLinkResponse{
+			ID:        id,
+			URL:       links.URL(req.Path, token),
+			ExpiresAt: expires,
+		})
+	}
+}
+
+func revokePreviewLink(links *previewLinks) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if b, err := hex.DecodeString(id); err != nil || len(b) != 8 {
+			http.Error(w, "Invalid link id", http.StatusBadRequest)
+			return
+		}
+
+		if err := links.Revoke(id); err != nil {
+			slog.Error("failed to revoke preview link", "id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Warn("revoked preview link", "id", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestPreviewLinks(t *testing.T) {
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	file := filepath.Join(t.TempDir(), "revoked")
+
+	links, err := newPreviewLinks("secret", nil, time.Hour, 24*time.Hour, file)
+	if err != nil {
+		t.Fatalf("create preview links: %s", err)
+	}
+	links.now = func() time.Time { return now }
+
+	token, id, expires, err := links.Sign(time.Hour)
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected expiry %s, got %s", now.Add(time.Hour), expires)
+	}
+
+	gotID, _, err := links.Verify(token)
+	if err != nil {
+		t.Fatalf("verify: %s", err)
+	}
+	if gotID != id {
+		t.Errorf("expected id %s, got %s", id, gotID)
+	}
+
+	otherKey, err := newPreviewLinks("other secret", nil, time.Hour, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("create preview links: %s", err)
+	}
+
+	extended := strings.Replace(token, ".", ".9", 1) // try to extend expiry
+	tests := []struct {
+		name  string
+		links *previewLinks
+		token string
+		want  error
+	}{
+		{name: "tampered expiry", links: links, token: extended, want: errPreviewTokenInvalid},
+		{name: "other secret", links: otherKey, token: token, want: errPreviewTokenInvalid},
+		{name: "garbage", links: links, token: "garbage", want: errPreviewTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tt.links.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	links.now = func() time.Time { return now.Add(time.Hour) }
+	if _, _, err := links.Verify(token); !errors.Is(err, errPreviewTokenExpired) {
+		t.Fatalf("expected expired error, got %v", err)
+	}
+	links.now = func() time.Time { return now }
+
+	if err := links.Revoke(id); err != nil {
+		t.Fatalf("revoke: %s", err)
+	}
+	if _, _, err := links.Verify(token); !errors.Is(err, errPreviewTokenRevoked) {
+		t.Fatalf("expected revoked error, got %v", err)
+	}
+
+	// revocations are loaded after restart
+	links, err = newPreviewLinks("secret", nil, time.Hour, 24*time.Hour, file)
+	if err != nil {
+		t.Fatalf("create preview links: %s", err)
+	}
+	links.now = func() time.Time { return now }
+	if _, _, err := links.Verify(token); !errors.Is(err, errPreviewTokenRevoked) {
+		t.Fatalf("expected revoked error after restart, got %v", err)
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	links, err := newPreviewLinks("secret", nil, time.Hour, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("create preview links: %s", err)
+	}
+	tr := newTracer(nil)
+	t.Cleanup(func() { _ = tr.Shutdown(context.Background()) })
+	previewFS := fstest.MapFS{"blog/draft/index.html": {Data: []byte("<h1>Draft</h1>")}}
+
+	mux := http.NewServeMux()
+	mux.Handle(previewPrefix+"/", newPreviewHandler(previewFS, links, tr))
+
+	var level slog.LevelVar
+	admin := newAdminHandler("secret", &level, &maintenance{}, time.Minute, links)
+
+	rec := adminRequest(t, admin, http.MethodPost, "/preview/links", `{"path":"/blog/draft/","ttl":600}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var link previewLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&link); err != nil {
+		t.Fatalf("decode response: %s", err)
+	}
+	if !strings.HasPrefix(link.URL, "/preview/blog/draft/?token=") {
+		t.Fatalf("unexpected link URL %s", link.URL)
+	}
+
+	get := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("X-Robots-Tag"); got != "noindex, nofollow" {
+			t.Errorf("%s: expected noindex, got %q", target, got)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s: expected no-store, got %q", target, got)
+		}
+		return rec
+	}
+
+	if rec := get("/preview/blog/draft/"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without token, got %d", rec.Code)
+	}
+
+	// token from the link is moved to cookie
+	rec = get(link.URL)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected status 302, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "/preview/blog/draft/" {
+		t.Errorf("expected redirect without token, got %s", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != previewCookie || !cookies[0].HttpOnly {
+		t.Fatalf("expected preview cookie, got %v", cookies)
+	}
+
+	rec = get("/preview/blog/draft/", cookies[0])
+	if rec.Code != http.StatusOK || rec.Body.String() != "<h1>Draft</h1>" {
+		t.Fatalf("expected draft page, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = adminRequest(t, admin, http.MethodDelete, "/preview/links/"+link.ID, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := get("/preview/blog/draft/", cookies[0]); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 after revoke, got %d", rec.Code)
+	}
+	if rec := get(link.URL); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for revoked link, got %d", rec.Code)
+	}
+}
+
+func TestCreatePreviewLinkTTL(t *testing.T) {
+	base, _ := url.Parse("https://getpid.dev/")
+	links, err := newPreviewLinks("secret", base, time.Hour, 24*time.Hour, "")
+	if err != nil {
+		t.Fatalf("create preview links: %s", err)
+	}
+	var level slog.LevelVar
+	admin := newAdminHandler("secret", &level, &maintenance{}, time.Minute, links)
+
+	rec := adminRequest(t, admin, http.MethodPost, "/preview/links", `{"path":"/blog/draft/","ttl":86401}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for too long ttl, got %d", rec.Code)
+	}
+
+	rec = adminRequest(t, admin, http.MethodPost, "/preview/links", "")
+	var link previewLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&link); err != nil {
+		t.Fatalf("decode response: %s", err)
+	}
+	if !strings.HasPrefix(link.URL, "https://getpid.dev/preview/?token=") {
+		t.Errorf("expected absolute link to preview root, got %s", link.URL)
+	}
+	if ttl := time.Until(link.ExpiresAt); ttl > time.Hour || ttl < 58*time.Minute {
+		t.Errorf("expected default ttl of an hour, got %s", ttl)
+	}
+
+	rec = adminRequest(t, admin, http.MethodDelete, "/preview/links/not-an-id", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid id, got %d", rec.Code)
+	}
+}
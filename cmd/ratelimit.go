@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipLimiter allows up to limit requests per client IP in a fixed window.
+// All counters are reset together when the window ends, so memory is bounded by the clients seen in one window.
+type ipLimiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newIPLimiter(limit int, window time.Duration) *ipLimiter {
+	return &ipLimiter{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		counts: make(map[string]int),
+	}
+}
+
+// Allow counts request from ip. If the limit is reached, it returns false and time until the window ends.
+func (l *ipLimiter) Allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		clear(l.counts)
+	}
+
+	if l.counts[ip] >= l.limit {
+		return false, l.start.Add(l.window).Sub(now)
+	}
+	l.counts[ip]++
+
+	return true, 0
+}
+
+// clientIP returns IP of the client. The server runs behind a proxy (see requestScheme),
+// so the last X-Forwarded-For address, added by the proxy, is used if set.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+		list := strings.Split(fwd[len(fwd)-1], ",")
+		if ip := strings.TrimSpace(list[len(list)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
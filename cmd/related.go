@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dmksnnk/blog/internal/related"
+)
+
+// loadRelated decodes related posts index generated by tools/related.
+func loadRelated(data []byte) (related.Index, error) {
+	var idx related.Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("decode related posts index: %w", err)
+	}
+
+	return idx, nil
+}
+
+type relatedResponse struct {
+	Related []related.Post `json:"related"`
+}
+
+// relatedPosts serves related posts of the page from "path" query parameter,
+// which is a page path, like /blog/golden-tests/, or a full page URL.
+func relatedPosts(idx related.Index) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Query().Get("path")
+		if p == "" {
+			http.Error(w, "missing path parameter", http.StatusBadRequest)
+			return
+		}
+		if u, err := url.Parse(p); err == nil {
+			p = u.Path
+		}
+
+		posts, ok := idx.Lookup(p)
+		if !ok {
+			http.Error(w, "page not found", http.StatusNotFound)
+			return
+		}
+
+		// index changes only with a new build
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		writeJSON(w, http.StatusOK, relatedResponse{Related: posts})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dmksnnk/blog/internal/related"
+)
+
+func TestRelatedPosts(t *testing.T) {
+	idx := related.Index{
+		"/blog/golden-tests/": {{Path: "/blog/test-smell/", Title: "Test smell", Score: 0.23}},
+		"/blog/test-smell/":   {},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/related", relatedPosts(idx))
+
+	tests := []struct {
+		name  string
+		path  string
+		code  int
+		posts int
+	}{
+		{name: "page", path: "/blog/golden-tests/", code: http.StatusOK, posts: 1},
+		{name: "without trailing slash", path: "/blog/golden-tests", code: http.StatusOK, posts: 1},
+		{name: "full URL", path: "https://getpid.dev/blog/golden-tests/", code: http.StatusOK, posts: 1},
+		{name: "no related posts", path: "/blog/test-smell/", code: http.StatusOK, posts: 0},
+		{name: "unknown page", path: "/blog/unknown/", code: http.StatusNotFound},
+		{name: "missing path", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/related?path="+url.QueryEscape(tt.path), nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d: %s", tt.code, rec.Code, rec.Body)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var resp relatedResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %s", err)
+			}
+			if resp.Related == nil || len(resp.Related) != tt.posts {
+				t.Fatalf("expected %d related posts, got %v", tt.posts, resp.Related)
+			}
+		})
+	}
+}
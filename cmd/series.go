@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// wordsPerMinute is the reading speed Hugo uses for .ReadingTime.
+const wordsPerMinute = 213
+
+var (
+	headingRe = regexp.MustCompile(`(?s)<h([2-6]) id="([^"]+)"[^>]*>(.*?)</h[2-6]>`)
+	// headingAnchorRe matches anchors PaperMod adds to headings, see anchored_headings.html.
+	headingAnchorRe = regexp.MustCompile(`<a hidden class="anchor"[^>]*>#</a>`)
+)
+
+// heading is a section heading of a post.
+type heading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// readingTime returns estimated reading time in minutes, the same as Hugo shows on the post.
+func (p post) readingTime() int {
+	return (len(strings.Fields(p.Content)) + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// loadHeadings sets headings of posts from their pages in fsys. Posts without a page get no headings.
+func loadHeadings(fsys fs.FS, posts []post) error {
+	for i, p := range posts {
+		name := path.Join(strings.TrimPrefix(p.path(), "/"), "index.html")
+		page, err := fs.ReadFile(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("no page of the post, outline will be empty", "path", p.path())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		posts[i].Headings = pageHeadings(string(page))
+	}
+
+	return nil
+}
+
+// pageHeadings returns h2-h6 headings of the post content, skipping headings of the page around it, like related posts.
+func pageHeadings(page string) []heading {
+	if _, content, ok := strings.Cut(page, `<div class="post-content">`); ok {
+		page, _, _ = strings.Cut(content, `<footer class="post-footer">`)
+	}
+
+	var headings []heading
+	for _, m := range headingRe.FindAllStringSubmatch(page, -1) {
+		level, _ := strconv.Atoi(m[1])
+		title := headingAnchorRe.ReplaceAllString(m[3], "")
+		headings = append(headings, heading{
+			Level: level,
+			ID:    m[2],
+			Title: strings.TrimSpace(plainText(title)),
+		})
+	}
+
+	return headings
+}
+
+type outlinePart struct {
+	Part        int       `json:"part"`
+	Title       string    `json:"title"`
+	Path        string    `json:"path"`
+	ReadingTime int       `json:"reading_time"`
+	Headings    []heading `json:"headings"`
+}
+
+type outlineResponse struct {
+	Series      term          `json:"series"`
+	ReadingTime int           `json:"reading_time"`
+	Parts       []outlinePart `json:"parts"`
+}
+
+// seriesOutline serves table of contents of the whole series: parts in reading order with their section headings.
+// Reading times are in minutes.
+func seriesOutline(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := tx.series[termSlug(r.PathValue("series"))]
+		if !ok {
+			http.Error(w, "series not found", http.StatusNotFound)
+			return
+		}
+
+		resp := outlineResponse{Series: s.term, Parts: make([]outlinePart, 0, len(s.posts))}
+		for i, p := range s.posts {
+			headings := p.Headings
+			if headings == nil {
+				headings = []heading{}
+			}
+			resp.Parts = append(resp.Parts, outlinePart{
+				Part:        i + 1,
+				Title:       p.Title,
+				Path:        p.path(),
+				ReadingTime: p.readingTime(),
+				Headings:    headings,
+			})
+			resp.ReadingTime += p.readingTime()
+		}
+
+		writeTaxonomyJSON(w, resp)
+	}
+}
+
+type navigationPart struct {
+	Part  int    `json:"part"`
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+type seriesNavigation struct {
+	Series     term            `json:"series"`
+	Part       int             `json:"part"`
+	TotalParts int             `json:"total_parts"`
+	Prev       *navigationPart `json:"prev"`
+	Next       *navigationPart `json:"next"`
+	// RemainingReadingTime is minutes to read the series from the start of this part to the end.
+	RemainingReadingTime int `json:"remaining_reading_time"`
+}
+
+type navigationResponse struct {
+	Series []seriesNavigation `json:"series"`
+}
+
+// navigation serves position of the post in its series, from "path" query parameter, which is a page path or a full URL.
+// Nothing is stored about the reader, the client keeps track of what was read.
+func navigation(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Query().Get("path")
+		if p == "" {
+			http.Error(w, "missing path parameter", http.StatusBadRequest)
+			return
+		}
+		if u, err := url.Parse(p); err == nil {
+			p = u.Path
+		}
+		if !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+
+		current, ok := tx.post(p)
+		if !ok {
+			http.Error(w, "post not found", http.StatusNotFound)
+			return
+		}
+
+		resp := navigationResponse{Series: []seriesNavigation{}}
+		for _, name := range current.Series {
+			s := tx.series[termSlug(name)]
+			for i, part := range s.posts {
+				if part.path() != p {
+					continue
+				}
+
+				nav := seriesNavigation{
+					Series:     s.term,
+					Part:       i + 1,
+					TotalParts: len(s.posts),
+				}
+				if i > 0 {
+					nav.Prev = &navigationPart{Part: i, Title: s.posts[i-1].Title, Path: s.posts[i-1].path()}
+				}
+				if i < len(s.posts)-1 {
+					nav.Next = &navigationPart{Part: i + 2, Title: s.posts[i+1].Title, Path: s.posts[i+1].path()}
+				}
+				for _, rest := range s.posts[i:] {
+					nav.RemainingReadingTime += rest.readingTime()
+				}
+
+				resp.Series = append(resp.Series, nav)
+				break
+			}
+		}
+
+		writeTaxonomyJSON(w, resp)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSeries(t *testing.T) {
+	page := func(headings string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`<html><body>
+<h2 id="toc">Table of Contents</h2>
+<div class="post-content">` + headings + `</div>
+<footer class="post-footer"><h2 id="related">Related</h2></footer>
+</body></html>`)}
+	}
+	fsys := fstest.MapFS{
+		"blog/http3/http3-server/index.html": page(`<h2 id="setup">Setup<a hidden class="anchor" aria-hidden="true" href="#setup">#</a></h2>
+<h3 id="tls">TLS &amp; certificates<a hidden class="anchor" aria-hidden="true" href="#tls">#</a></h3>`),
+		"blog/http3/http3-client/index.html": page(`<h2 id="dial"><code>Dial</code></h2>`),
+	}
+
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 12, 0, 0, 0, time.UTC) }
+	words := func(n int) string { return strings.Repeat("word ", n) }
+	posts := []post{
+		{Title: "Client Stream", Permalink: "https://getpid.dev/blog/http3/client-stream/", Date: day(28), Series: []string{"HTTP3"}, Content: words(100)},
+		{Title: "Writing HTTP/3 Server", Permalink: "https://getpid.dev/blog/http3/http3-server/", Date: day(8), Series: []string{"HTTP3"}, Content: words(500)},
+		{Title: "HTTP/3 Client", Permalink: "https://getpid.dev/blog/http3/http3-client/", Date: day(9), Series: []string{"HTTP3"}, Content: words(214)},
+		{Title: "Golden Tests", Permalink: "https://getpid.dev/blog/golden-tests/", Date: day(20)},
+	}
+	if err := loadHeadings(fsys, posts); err != nil {
+		t.Fatalf("load headings: %s", err)
+	}
+	tx := newTaxonomy(posts)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/series/{series}/outline", seriesOutline(tx))
+	mux.HandleFunc("GET /api/navigation", navigation(tx))
+
+	get := func(t *testing.T, target string, wantCode int, resp any) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != wantCode {
+			t.Fatalf("expected status %d, got %d: %s", wantCode, rec.Code, rec.Body)
+		}
+		if resp != nil {
+			if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+				t.Fatalf("decode response: %s", err)
+			}
+		}
+	}
+
+	t.Run("outline", func(t *testing.T) {
+		var resp outlineResponse
+		get(t, "/api/series/http3/outline", http.StatusOK, &resp)
+
+		if resp.ReadingTime != 6 {
+			t.Errorf("expected reading time 6, got %d", resp.ReadingTime)
+		}
+		if len(resp.Parts) != 3 {
+			t.Fatalf("expected 3 parts, got %+v", resp.Parts)
+		}
+		if resp.Parts[0].Path != "/blog/http3/http3-server/" || resp.Parts[0].ReadingTime != 3 {
+			t.Errorf("unexpected first part %+v", resp.Parts[0])
+		}
+
+		want := []heading{
+			{Level: 2, ID: "setup", Title: "Setup"},
+			{Level: 3, ID: "tls", Title: "TLS & certificates"},
+		}
+		if len(resp.Parts[0].Headings) != len(want) {
+			t.Fatalf("expected headings %v, got %v", want, resp.Parts[0].Headings)
+		}
+		for i := range want {
+			if resp.Parts[0].Headings[i] != want[i] {
+				t.Errorf("expected %v, got %v", want[i], resp.Parts[0].Headings[i])
+			}
+		}
+		if got := resp.Parts[1].Headings; len(got) != 1 || got[0].Title != "Dial" {
+			t.Errorf("expected Dial heading, got %v", got)
+		}
+		if resp.Parts[2].Headings == nil || len(resp.Parts[2].Headings) != 0 {
+			t.Errorf("expected empty headings of part without page, got %v", resp.Parts[2].Headings)
+		}
+	})
+
+	t.Run("outline of unknown series", func(t *testing.T) {
+		get(t, "/api/series/missing/outline", http.StatusNotFound, nil)
+	})
+
+	t.Run("navigation", func(t *testing.T) {
+		var resp navigationResponse
+		get(t, "/api/navigation?path=https://getpid.dev/blog/http3/http3-client", http.StatusOK, &resp)
+
+		if len(resp.Series) != 1 {
+			t.Fatalf("expected 1 series, got %+v", resp.Series)
+		}
+		nav := resp.Series[0]
+		if nav.Series.Slug != "http3" || nav.Part != 2 || nav.TotalParts != 3 {
+			t.Errorf("unexpected position %+v", nav)
+		}
+		if nav.Prev == nil || nav.Prev.Part != 1 || nav.Prev.Path != "/blog/http3/http3-server/" {
+			t.Errorf("unexpected prev %+v", nav.Prev)
+		}
+		if nav.Next == nil || nav.Next.Part != 3 || nav.Next.Title != "Client Stream" {
+			t.Errorf("unexpected next %+v", nav.Next)
+		}
+		if nav.RemainingReadingTime != 3 {
+			t.Errorf("expected remaining reading time 3, got %d", nav.RemainingReadingTime)
+		}
+	})
+
+	t.Run("navigation of last part", func(t *testing.T) {
+		var resp navigationResponse
+		get(t, "/api/navigation?path=/blog/http3/client-stream/", http.StatusOK, &resp)
+
+		if len(resp.Series) != 1 || resp.Series[0].Next != nil || resp.Series[0].Prev == nil {
+			t.Errorf("unexpected navigation %+v", resp.Series)
+		}
+	})
+
+	t.Run("navigation of post without series", func(t *testing.T) {
+		var resp navigationResponse
+		get(t, "/api/navigation?path=/blog/golden-tests/", http.StatusOK, &resp)
+
+		if resp.Series == nil || len(resp.Series) != 0 {
+			t.Errorf("expected no series, got %+v", resp.Series)
+		}
+	})
+
+	t.Run("navigation of unknown post", func(t *testing.T) {
+		get(t, "/api/navigation?path=/blog/missing/", http.StatusNotFound, nil)
+	})
+
+	t.Run("navigation without path", func(t *testing.T) {
+		get(t, "/api/navigation", http.StatusBadRequest, nil)
+	})
+}
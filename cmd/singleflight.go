@@ -0,0 +1,43 @@
+package main
+
+import "sync"
+
+// singleflight runs a function once for concurrent calls with the same key,
+// callers which come while it runs wait for it and share the result.
+type singleflight[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flight[T]
+}
+
+type flight[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Do runs fn, or waits for fn already running with the key, and returns its result.
+func (g *singleflight[T]) Do(key string, fn func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+
+	if g.calls == nil {
+		g.calls = make(map[string]*flight[T])
+	}
+	c := &flight[T]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() { // also on panic, so waiting callers don't hang
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSingleflight(t *testing.T) {
+	var g singleflight[int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = g.Do("key", func() (int, error) {
+			close(started)
+			<-release
+			return 42, nil
+		})
+	}()
+	<-started
+
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = g.Do("key", func() (int, error) {
+				select {
+				case <-release: // came after the first call, runs again
+				default:
+					t.Error("expected to wait for the running call")
+				}
+				return 42, nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // let callers wait for the running call
+	close(release)
+	wg.Wait()
+
+	for i, r := range results {
+		if r != 42 {
+			t.Errorf("result %d: expected 42, got %d", i, r)
+		}
+	}
+
+	// results are not cached after the call
+	wantErr := errors.New("failed")
+	if _, err := g.Do("key", func() (int, error) { return 0, wantErr }); !errors.Is(err, wantErr) {
+		t.Errorf("expected error %v, got %v", wantErr, err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"example.com/golden/golden"
+	"github.com/dmksnnk/blog/internal/related"
+)
+
+// snapshotHeaders are response headers stored in snapshots.
+// Headers which change between runs, like Date, Last-Modified or X-Request-ID, are skipped.
+var snapshotHeaders = []string{
+	"Accept-Ranges",
+	"Cache-Control",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"Link",
+	"Location",
+	"Retry-After",
+	"Vary",
+}
+
+// TestSiteSnapshot requests every file of the fixture site through the full handler chain
+// and compares responses with golden files in testdata/snapshots/.
+// Run with -update to regenerate snapshots.
+func TestSiteSnapshot(t *testing.T) {
+	siteFS := os.DirFS("testdata/site")
+	srv := newSiteServer(t, siteFS)
+
+	err := fs.WalkDir(siteFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		urlPath := "/" + name
+		if path.Base(name) == "index.html" {
+			urlPath = strings.TrimSuffix(urlPath, "index.html")
+		}
+
+		t.Run(urlPath, func(t *testing.T) {
+			var snapshot strings.Builder
+			for _, encoding := range []string{"", "gzip"} {
+				req := newSiteRequest(t, http.MethodGet, urlPath)
+				req.Header.Set("Accept-Encoding", encoding)
+				snapshot.WriteString(snapshotResponse(t, srv, req))
+			}
+
+			golden.Assert(t, snapshotName(urlPath), []byte(snapshot.String()))
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk site: %s", err)
+	}
+}
+
+func TestSiteSnapshotEdgeCases(t *testing.T) {
+	srv := newSiteServer(t, os.DirFS("testdata/site"))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+	}{
+		{name: "head", method: http.MethodHead, path: "/blog/golden-tests/"},
+		{name: "head gzip", method: http.MethodHead, path: "/blog/golden-tests/", headers: map[string]string{"Accept-Encoding": "gzip"}},
+		{name: "range", path: "/images/golden-brick.svg", headers: map[string]string{"Range": "bytes=0-99"}},
+		{name: "range gzip", path: "/images/golden-brick.svg", headers: map[string]string{"Range": "bytes=0-99", "Accept-Encoding": "gzip"}},
+		{name: "range not satisfiable", path: "/robots.txt", headers: map[string]string{"Range": "bytes=1000-"}},
+		{name: "missing page", path: "/blog/missing/"},
+		{name: "missing file", path: "/images/missing.svg", headers: map[string]string{"Accept-Encoding": "gzip"}},
+		{name: "missing trailing slash", path: "/blog/golden-tests"},
+		{name: "index.html", path: "/blog/golden-tests/index.html"},
+		{name: "double slashes", path: "//blog//golden-tests/"},
+		{name: "accept encoding br", path: "/", headers: map[string]string{"Accept-Encoding": "br"}},
+		{name: "accept encoding list", path: "/", headers: map[string]string{"Accept-Encoding": "deflate, gzip;q=1.0, *;q=0.5"}},
+		{name: "accept encoding gzip png", path: "/blog/go-webview-gui/index_page.png", headers: map[string]string{"Accept-Encoding": "gzip"}},
+		{name: "post", method: http.MethodPost, path: "/"},
+		{name: "health", path: "/-/health"},
+		{name: "og image", path: "/og/golden-tests.png", headers: map[string]string{"Accept-Encoding": "gzip"}},
+		{name: "og image missing", path: "/og/missing.png"},
+		{name: "resized image", path: "/img/blog/go-webview-gui/index_page.png?w=320&v=ce3698a91bae5c5d"},
+		{name: "resized image unversioned", path: "/img/blog/go-webview-gui/index_page.png?w=640&q=50"},
+		{name: "resized image width not allowed", path: "/img/blog/go-webview-gui/index_page.png?w=321"},
+		{name: "resized image not an image", path: "/img/index.html?w=320"},
+		{name: "json feed", path: "/feed.json", headers: map[string]string{"Accept-Encoding": "gzip"}},
+		{name: "atom feed", path: "/atom.xml"},
+		{name: "tags", path: "/api/tags"},
+		{name: "tag", path: "/api/tags/go"},
+		{name: "tag missing", path: "/api/tags/rust"},
+		{name: "posts", path: "/api/posts?tag=go&per_page=1"},
+		{name: "atom feed not modified since", path: "/atom.xml", headers: map[string]string{"If-Modified-Since": "Thu, 04 Jun 2025 16:27:15 GMT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := newSiteRequest(t, method, tt.path)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			golden.Assert(t, snapshotName("edge/"+tt.name), []byte(snapshotResponse(t, srv, req)))
+		})
+	}
+}
+
+func newSiteServer(t *testing.T, siteFS fs.FS) *httptest.Server {
+	t.Helper()
+
+	canonical, err := url.Parse("https://getpid.dev/")
+	if err != nil {
+		t.Fatalf("parse canonical URL: %s", err)
+	}
+
+	tr := newTracer(nil)
+	t.Cleanup(func() { _ = tr.Shutdown(context.Background()) })
+
+	posts, err := loadPosts(siteFS)
+	if err != nil {
+		t.Fatalf("load posts: %s", err)
+	}
+	if err := loadHeadings(siteFS, posts); err != nil {
+		t.Fatalf("load headings: %s", err)
+	}
+	ogImgs, err := newOGImages(posts, "getpid.dev", t.TempDir())
+	if err != nil {
+		t.Fatalf("create Open Graph images: %s", err)
+	}
+
+	resizer := newImageResizer(siteFS, []int{320, 640}, []int{50}, t.TempDir(), 1<<20)
+
+	siteFeeds, err := loadFeeds(siteFS, "getpid.dev")
+	if err != nil {
+		t.Fatalf("load feeds: %s", err)
+	}
+
+	blogSite := &site{
+		name: "blog",
+		fsys: siteFS,
+		handler: newBlogHandler(blogDeps{
+			fsys:     siteFS,
+			related:  related.Index{},
+			og:       ogImgs,
+			resizer:  resizer,
+			feeds:    siteFeeds,
+			taxonomy: newTaxonomy(posts),
+		}, canonical, tr),
+	}
+	srv := httptest.NewServer(newHandler([]*site{blogSite}, tr, &maintenance{}))
+	t.Cleanup(srv.Close)
+
+	srv.Client().Transport.(*http.Transport).DisableCompression = true
+	srv.Client().CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return srv
+}
+
+func newSiteRequest(t *testing.T, method, urlPath string) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(method, "http://getpid.dev", nil)
+	if err != nil {
+		t.Fatalf("create request: %s", err)
+	}
+	req.URL.Path, req.URL.RawQuery, _ = strings.Cut(urlPath, "?")
+
+	return req
+}
+
+// snapshotResponse sends the request to the server and serializes the response:
+// status, selected headers and SHA-256 of the body.
+// Gzipped bodies are decompressed before hashing, so snapshots don't depend on compression level.
+func snapshotResponse(t *testing.T, srv *httptest.Server, req *http.Request) string {
+	t.Helper()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("parse server URL: %s", err)
+	}
+	req.Host = req.URL.Host
+	req.URL.Host = srvURL.Host
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("make request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", req.Method, req.URL.Path)
+	if req.URL.RawQuery != "" {
+		fmt.Fprintf(&b, "?%s", req.URL.RawQuery)
+	}
+	if enc := req.Header.Get("Accept-Encoding"); enc != "" {
+		fmt.Fprintf(&b, " (Accept-Encoding: %s)", enc)
+	}
+	if r := req.Header.Get("Range"); r != "" {
+		fmt.Fprintf(&b, " (Range: %s)", r)
+	}
+	fmt.Fprintf(&b, "\n%s\n", resp.Status)
+
+	for _, h := range snapshotHeaders {
+		for _, v := range resp.Header.Values(h) {
+			fmt.Fprintf(&b, "%s: %s\n", h, v)
+		}
+	}
+
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" && req.Method != http.MethodHead {
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			fmt.Fprintf(&b, "Body: invalid gzip: %s\n\n", err)
+			return b.String()
+		}
+		defer gr.Close()
+		body = gr
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(h, body)
+	if err != nil {
+		t.Fatalf("read body: %s", err)
+	}
+	fmt.Fprintf(&b, "Body: %d bytes, sha256 %s\n\n", n, hex.EncodeToString(h.Sum(nil)))
+
+	return b.String()
+}
+
+// snapshotName converts URL path to golden file name.
+func snapshotName(urlPath string) string {
+	name := strings.Trim(urlPath, "/")
+	if name == "" {
+		name = "index"
+	}
+
+	return path.Join("snapshots", strings.ReplaceAll(name, " ", "_")+".golden")
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// embeddedRoot is the root of the site config which refers to the embedded blog.
+const embeddedRoot = "embedded"
+
+// sitesConfig is the file with sites served by the Host header, see SITES_CONFIG.
+//
+//	sites:
+//	  - name: blog
+//	    hosts: [getpid.dev, www.getpid.dev]
+//	    root: embedded
+//	  - name: docs
+//	    hosts: [docs.getpid.dev]
+//	    root: /srv/docs.zip
+//	    base_url: https://docs.getpid.dev/
+//	    cache:
+//	      - match: "*.css"
+//	        cache_control: public, max-age=31536000, immutable
+//	    redirects:
+//	      - from: /v1/*
+//	        to: /v2/
+//	    headers:
+//	      X-Frame-Options: DENY
+type sitesConfig struct {
+	Sites []siteConfig `yaml:"sites"`
+}
+
+type siteConfig struct {
+	// Name identifies the site in health and logs.
+	Name string `yaml:"name"`
+	// Hosts are host names of the site, without port.
+	Hosts []string `yaml:"hosts"`
+	// Root is a directory, a .zip bundle or "embedded" for the blog.
+	Root string `yaml:"root"`
+	// BaseURL enables canonical redirects for directory and bundle sites.
+	// The embedded blog uses BASE_URL and CANONICAL_REDIRECT instead.
+	BaseURL string `yaml:"base_url"`
+	// Cache rules take precedence over the default caching by file extension, the first matching rule wins.
+	Cache     []cacheRule       `yaml:"cache"`
+	Redirects []redirectRule    `yaml:"redirects"`
+	Headers   map[string]string `yaml:"headers"`
+}
+
+type cacheRule struct {
+	// Match is a path.Match pattern. Patterns with a slash match the request path, others match its last element.
+	Match        string `yaml:"match"`
+	CacheControl string `yaml:"cache_control"`
+}
+
+type redirectRule struct {
+	// From is the request path. If it ends with *, it is a prefix and the rest of the path is appended to To.
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Status defaults to 301.
+	Status int `yaml:"status"`
+}
+
+func loadSitesConfig(name string) ([]siteConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var cfg sitesConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", name, err)
+	}
+
+	if err := validateSites(cfg.Sites); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return cfg.Sites, nil
+}
+
+func validateSites(sites []siteConfig) error {
+	names := make(map[string]bool)
+	hosts := make(map[string]string)
+	embedded := false
+	for i, s := range sites {
+		if s.Name == "" {
+			return fmt.Errorf("site %d: name is required", i)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("site %s: duplicate name", s.Name)
+		}
+		names[s.Name] = true
+
+		if s.Root == "" {
+			return fmt.Errorf("site %s: root is required", s.Name)
+		}
+		if s.Root == embeddedRoot {
+			if embedded {
+				return fmt.Errorf("site %s: embedded blog is configured twice", s.Name)
+			}
+			if s.BaseURL != "" {
+				return fmt.Errorf("site %s: base URL of the embedded blog is set with BASE_URL", s.Name)
+			}
+			embedded = true
+		} else if len(s.Hosts) == 0 { // the blog serves unknown hosts, others need at least one
+			return fmt.Errorf("site %s: hosts are required", s.Name)
+		}
+
+		for _, h := range s.Hosts {
+			h = strings.ToLower(h)
+			if other, ok := hosts[h]; ok {
+				return fmt.Errorf("site %s: host %s is already used by site %s", s.Name, h, other)
+			}
+			hosts[h] = s.Name
+		}
+
+		for _, c := range s.Cache {
+			if _, err := path.Match(c.Match, ""); err != nil || c.Match == "" {
+				return fmt.Errorf("site %s: invalid cache rule pattern %q", s.Name, c.Match)
+			}
+		}
+
+		for _, rd := range s.Redirects {
+			if !strings.HasPrefix(rd.From, "/") || rd.To == "" {
+				return fmt.Errorf("site %s: redirect needs absolute from path and a target, got %q -> %q", s.Name, rd.From, rd.To)
+			}
+			if _, err := url.Parse(rd.To); err != nil {
+				return fmt.Errorf("site %s: redirect target: %w", s.Name, err)
+			}
+			if rd.Status != 0 && (rd.Status < 300 || rd.Status > 399) {
+				return fmt.Errorf("site %s: redirect status %d is not a redirect", s.Name, rd.Status)
+			}
+		}
+	}
+
+	return nil
+}
+
+// site is one of the sites served by the public listener.
+type site struct {
+	name    string
+	hosts   []string
+	fsys    fs.FS
+	handler http.Handler
+}
+
+// Ready reports whether the site has the index page. Directories can change while running, so it is checked every time.
+func (s *site) Ready() error {
+	_, err := fs.Stat(s.fsys, "index.html")
+	return err
+}
+
+// newSites creates sites from config. The blog is the first site, it serves hosts not configured for other sites.
+// Config of the blog is applied to it if there is a site with the embedded root.
+func newSites(cfgs []siteConfig, blog *site, tr *tracer) ([]*site, error) {
+	sites := []*site{blog}
+	for _, cfg := range cfgs {
+		if cfg.Root == embeddedRoot {
+			blog.name = cfg.Name
+			blog.hosts = cfg.Hosts
+			blog.handler = SiteMiddleware(cfg, blog.handler)
+			continue
+		}
+
+		fsys, err := openSiteRoot(cfg.Root)
+		if err != nil {
+			return nil, fmt.Errorf("site %s: %w", cfg.Name, err)
+		}
+
+		var canonical *url.URL
+		if cfg.BaseURL != "" {
+			canonical, err = canonicalBaseURL(cfg.BaseURL, fsys)
+			if err != nil {
+				return nil, fmt.Errorf("site %s: %w", cfg.Name, err)
+			}
+		}
+
+		sites = append(sites, &site{
+			name:    cfg.Name,
+			hosts:   cfg.Hosts,
+			fsys:    fsys,
+			handler: SiteMiddleware(cfg, newStaticSiteHandler(fsys, canonical, tr)),
+		})
+	}
+
+	return sites, nil
+}
+
+// openSiteRoot opens a directory or a .zip bundle. Bundles stay open while the server runs.
+func openSiteRoot(root string) (fs.FS, error) {
+	if filepath.Ext(root) == ".zip" {
+		bundle, err := zip.OpenReader(root)
+		if err != nil {
+			return nil, fmt.Errorf("open bundle: %w", err)
+		}
+
+		return bundle, nil
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory or a .zip bundle", root)
+	}
+
+	return os.DirFS(root), nil
+}
+
+// newStaticSiteHandler serves files of fsys, canonical redirects are disabled if canonical is nil.
+func newStaticSiteHandler(fsys fs.FS, canonical *url.URL, tr *tracer) http.Handler {
+	var handler http.Handler = SpanMiddleware(tr, "gzip", GzipMiddleware(
+		SpanMiddleware(tr, "cache", CacheMiddleware(
+			SpanMiddleware(tr, "file", http.FileServerFS(fsys)),
+		)),
+	))
+	if canonical != nil {
+		handler = CanonicalMiddleware(canonical, fsys, handler)
+	}
+
+	return handler
+}
+
+// SiteMiddleware applies site config: sets headers, redirects and sets Cache-Control by the cache rules.
+func SiteMiddleware(cfg siteConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range cfg.Headers {
+			w.Header().Set(k, v)
+		}
+
+		if target, code, ok := matchRedirect(cfg.Redirects, r.URL.Path); ok {
+			http.Redirect(w, r, withQuery(target, r.URL.RawQuery), code)
+			return
+		}
+
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if cc, ok := matchCacheRule(cfg.Cache, r.URL.Path); ok {
+				w.Header().Set("Cache-Control", cc)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func matchRedirect(rules []redirectRule, p string) (string, int, bool) {
+	for _, rd := range rules {
+		code := rd.Status
+		if code == 0 {
+			code = http.StatusMovedPermanently
+		}
+
+		if prefix, ok := strings.CutSuffix(rd.From, "*"); ok {
+			if rest, ok := strings.CutPrefix(p, prefix); ok {
+				to, query, hasQuery := strings.Cut(rd.To, "?") // rest goes to the path, before query of the target
+				if hasQuery {
+					return to + rest + "?" + query, code, true
+				}
+				return to + rest, code, true
+			}
+			continue
+		}
+
+		if p == rd.From {
+			return rd.To, code, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// withQuery adds query of the request to the redirect target, keeping query of the target.
+func withQuery(target, rawQuery string) string {
+	if rawQuery == "" {
+		return target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil { // targets are validated with the config
+		return target
+	}
+	if u.RawQuery == "" {
+		u.RawQuery = rawQuery
+		return u.String()
+	}
+
+	q := u.Query()
+	reqQuery, _ := url.ParseQuery(rawQuery)
+	for k, vs := range reqQuery {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func matchCacheRule(rules []cacheRule, p string) (string, bool) {
+	for _, c := range rules {
+		name := path.Base(p)
+		if strings.Contains(c.Match, "/") {
+			name = p
+		}
+
+		if ok, _ := path.Match(c.Match, name); ok {
+			return c.CacheControl, true
+		}
+	}
+
+	return "", false
+}
+
+// hostRouter routes requests to sites by host, requests to unknown hosts go to the fallback site.
+type hostRouter struct {
+	sites    map[string]*site
+	fallback *site
+}
+
+func newHostRouter(sites []*site) *hostRouter {
+	hr := &hostRouter{
+		sites:    make(map[string]*site),
+		fallback: sites[0],
+	}
+	for _, s := range sites {
+		for _, h := range s.hosts {
+			hr.sites[strings.ToLower(h)] = s
+		}
+	}
+
+	return hr
+}
+
+func (hr *hostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := requestHost(r)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	s, ok := hr.sites[strings.ToLower(host)]
+	if !ok {
+		s = hr.fallback
+	}
+
+	s.handler.ServeHTTP(w, r)
+}
+
+type siteHealth struct {
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+type healthStatus struct {
+	Status string                `json:"status"`
+	Sites  map[string]siteHealth `json:"sites,omitempty"`
+}
+
+// health reports readiness of every site. Status is "degraded" if any site is not ready,
+// but it is 503 only if the first site is not ready, so a broken secondary site doesn't take the blog down.
+func health(sites []*site) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthStatus{Status: "ok", Sites: make(map[string]siteHealth, len(sites))}
+		code := http.StatusOK
+		for i, s := range sites {
+			err := s.Ready()
+			if err == nil {
+				resp.Sites[s.name] = siteHealth{Ready: true}
+				continue
+			}
+
+			if errors.Is(err, fs.ErrNotExist) {
+				err = errors.New("index.html not found")
+			}
+			resp.Sites[s.name] = siteHealth{Error: err.Error()}
+			resp.Status = "degraded"
+			if i == 0 {
+				resp.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, code, resp)
+	}
+}
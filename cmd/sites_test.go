@@ -0,0 +1,237 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadSitesConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			config: `
+sites:
+  - name: blog
+    root: embedded
+  - name: docs
+    hosts: [docs.getpid.dev]
+    root: /srv/docs
+    redirects:
+      - {from: /v1/*, to: /v2/, status: 302}
+`,
+		},
+		{name: "unknown field", config: "sites:\n  - name: docs\n    host: docs.getpid.dev\n", wantErr: "field host not found"},
+		{name: "missing name", config: "sites:\n  - root: /srv/docs\n", wantErr: "name is required"},
+		{name: "missing hosts", config: "sites:\n  - name: docs\n    root: /srv/docs\n", wantErr: "hosts are required"},
+		{
+			name:    "duplicate host",
+			config:  "sites:\n  - {name: a, root: /a, hosts: [a.dev]}\n  - {name: b, root: /b, hosts: [A.dev]}\n",
+			wantErr: "host a.dev is already used by site a",
+		},
+		{name: "embedded base URL", config: "sites:\n  - {name: blog, root: embedded, base_url: https://getpid.dev/}\n", wantErr: "BASE_URL"},
+		{name: "bad cache pattern", config: "sites:\n  - {name: a, root: /a, hosts: [a.dev], cache: [{match: '[', cache_control: no-cache}]}\n", wantErr: "invalid cache rule"},
+		{name: "bad redirect status", config: "sites:\n  - {name: a, root: /a, hosts: [a.dev], redirects: [{from: /a, to: /b, status: 200}]}\n", wantErr: "not a redirect"},
+		{name: "bad redirect target", config: "sites:\n  - {name: a, root: /a, hosts: [a.dev], redirects: [{from: /a, to: '/b%zz'}]}\n", wantErr: "redirect target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "sites.yaml")
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatalf("write config: %s", err)
+			}
+
+			_, err := loadSitesConfig(file)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	tr := newTracer(nil)
+	t.Cleanup(func() { _ = tr.Shutdown(context.Background()) })
+
+	dir := t.TempDir()
+	docsDir := filepath.Join(dir, "docs")
+	if err := os.MkdirAll(docsDir, 0o755); err != nil {
+		t.Fatalf("create docs dir: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "index.html"), []byte("docs"), 0o600); err != nil {
+		t.Fatalf("write docs index: %s", err)
+	}
+	bundle := filepath.Join(dir, "talks.zip")
+	writeZip(t, bundle, map[string]string{"index.html": "talks", "slides/index.html": "slides"})
+	emptyDir := filepath.Join(dir, "empty")
+	if err := os.MkdirAll(emptyDir, 0o755); err != nil {
+		t.Fatalf("create empty dir: %s", err)
+	}
+
+	blogSite := &site{
+		name: "blog",
+		fsys: fstest.MapFS{"index.html": {Data: []byte("blog")}},
+		handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("blog"))
+		}),
+	}
+	sites, err := newSites([]siteConfig{
+		{Name: "blog", Root: embeddedRoot, Hosts: []string{"getpid.dev"}, Headers: map[string]string{"X-Site": "blog"}},
+		{Name: "docs", Root: docsDir, Hosts: []string{"docs.getpid.dev"}},
+		{Name: "talks", Root: bundle, Hosts: []string{"talks.getpid.dev"}, BaseURL: "https://talks.getpid.dev/"},
+		{Name: "empty", Root: emptyDir, Hosts: []string{"empty.getpid.dev"}},
+	}, blogSite, tr)
+	if err != nil {
+		t.Fatalf("create sites: %s", err)
+	}
+	handler := newHandler(sites, tr, &maintenance{})
+
+	get := func(host, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		host     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{host: "getpid.dev", path: "/", wantCode: http.StatusOK, wantBody: "blog"},
+		{host: "unknown.dev", path: "/", wantCode: http.StatusOK, wantBody: "blog"},
+		{host: "DOCS.getpid.dev:8080", path: "/", wantCode: http.StatusOK, wantBody: "docs"},
+		{host: "talks.getpid.dev", path: "/slides/", wantCode: http.StatusOK, wantBody: "slides"},
+		{host: "talks.getpid.dev", path: "/slides", wantCode: http.StatusMovedPermanently},
+		{host: "empty.getpid.dev", path: "/missing.html", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			rec := get(tt.host, tt.path)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Fatalf("expected body %q, got %q", tt.wantBody, rec.Body)
+			}
+		})
+	}
+
+	if got := get("getpid.dev", "/").Header().Get("X-Site"); got != "blog" {
+		t.Errorf("expected blog headers from config, got %q", got)
+	}
+	if got := get("talks.getpid.dev", "/slides").Header().Get("Location"); got != "https://talks.getpid.dev/slides/" {
+		t.Errorf("expected canonical redirect, got %q", got)
+	}
+
+	rec := get("docs.getpid.dev", "/-/health")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected health status 200 with a secondary site down, got %d", rec.Code)
+	}
+	var status healthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("decode health: %s", err)
+	}
+	if status.Status != "degraded" || !status.Sites["docs"].Ready || !status.Sites["talks"].Ready || status.Sites["empty"].Ready {
+		t.Fatalf("unexpected health: %+v", status)
+	}
+
+	blogSite.fsys = fstest.MapFS{}
+	if rec := get("getpid.dev", "/-/health"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected health status 503 with the blog down, got %d", rec.Code)
+	}
+}
+
+func TestSiteMiddleware(t *testing.T) {
+	cfg := siteConfig{
+		Cache: []cacheRule{
+			{Match: "/assets/*", CacheControl: "no-cache"},
+			{Match: "*.css", CacheControl: "public, max-age=60"},
+		},
+		Redirects: []redirectRule{
+			{From: "/old/", To: "/new/"},
+			{From: "/v1/*", To: "/v2/", Status: http.StatusFound},
+			{From: "/promo/*", To: "https://getpid.dev/blog/?utm_source=promo"},
+		},
+		Headers: map[string]string{"X-Frame-Options": "DENY"},
+	}
+	handler := SiteMiddleware(cfg, CacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tests := []struct {
+		path         string
+		wantCode     int
+		wantLocation string
+		wantCache    string
+	}{
+		{path: "/old/", wantCode: http.StatusMovedPermanently, wantLocation: "/new/"},
+		{path: "/v1/guide/?tab=go", wantCode: http.StatusFound, wantLocation: "/v2/guide/?tab=go"},
+		{path: "/promo/go/?tab=go", wantCode: http.StatusMovedPermanently, wantLocation: "https://getpid.dev/blog/go/?tab=go&utm_source=promo"},
+		{path: "/promo/", wantCode: http.StatusMovedPermanently, wantLocation: "https://getpid.dev/blog/?utm_source=promo"},
+		{path: "/assets/app.css", wantCode: http.StatusOK, wantCache: "no-cache"},
+		{path: "/style.css", wantCode: http.StatusOK, wantCache: "public, max-age=60"},
+		{path: "/script.js", wantCode: http.StatusOK, wantCache: " public, max-age=86400"}, // default by extension
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("expected location %q, got %q", tt.wantLocation, got)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wantCache, got)
+			}
+			if got := rec.Header().Get("X-Frame-Options"); got != "DENY" {
+				t.Errorf("expected X-Frame-Options from config, got %q", got)
+			}
+		})
+	}
+}
+
+func writeZip(t *testing.T, name string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("create zip: %s", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create zip entry: %s", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("write zip entry: %s", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %s", err)
+	}
+}
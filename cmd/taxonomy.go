@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPostsPerPage = 10
+	maxPostsPerPage     = 100
+)
+
+// term is a tag or a series.
+type term struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	// URL is the path of the term page, e.g. /tags/go/.
+	URL   string `json:"url"`
+	Count int    `json:"count"`
+}
+
+type termPosts struct {
+	term  term
+	posts []post
+}
+
+// taxonomy indexes posts by tags and series.
+type taxonomy struct {
+	posts  []post // newest first
+	byPath map[string]post
+	tags   map[string]*termPosts
+	series map[string]*termPosts
+}
+
+func newTaxonomy(posts []post) *taxonomy {
+	tx := &taxonomy{
+		posts:  slices.Clone(posts),
+		byPath: make(map[string]post, len(posts)),
+		tags:   make(map[string]*termPosts),
+		series: make(map[string]*termPosts),
+	}
+	slices.SortStableFunc(tx.posts, func(a, b post) int { return b.Date.Compare(a.Date) })
+
+	for _, p := range tx.posts {
+		tx.byPath[p.path()] = p
+		addTerms(tx.tags, "tags", p.Tags, p)
+		addTerms(tx.series, "series", p.Series, p)
+	}
+
+	// parts are ordered by weight and then by date, like Hugo orders pages
+	for _, s := range tx.series {
+		slices.SortStableFunc(s.posts, func(a, b post) int {
+			if a.Weight != b.Weight {
+				if a.Weight == 0 || b.Weight == 0 { // pages without weight go last
+					return b.Weight - a.Weight
+				}
+				return a.Weight - b.Weight
+			}
+			return a.Date.Compare(b.Date)
+		})
+	}
+
+	return tx
+}
+
+// post returns post by URL path, e.g. /blog/golden-tests/.
+func (tx *taxonomy) post(urlPath string) (post, bool) {
+	p, ok := tx.byPath[urlPath]
+	return p, ok
+}
+
+func addTerms(terms map[string]*termPosts, taxonomy string, names []string, p post) {
+	for _, name := range names {
+		slug := termSlug(name)
+		t, ok := terms[slug]
+		if !ok {
+			t = &termPosts{term: term{Name: name, Slug: slug, URL: "/" + taxonomy + "/" + slug + "/"}}
+			terms[slug] = t
+		}
+		t.term.Count++
+		t.posts = append(t.posts, p)
+	}
+}
+
+// termSlug returns slug of the term as Hugo's urlize does for plain names: lower case with dashes instead of spaces
+// and slashes, so a term is a single path segment, like "ci-cd" for "CI/CD".
+func termSlug(name string) string {
+	name = strings.ReplaceAll(name, "/", " ")
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+func sortedTerms(terms map[string]*termPosts) []term {
+	list := make([]term, 0, len(terms))
+	for _, t := range terms {
+		list = append(list, t.term)
+	}
+	slices.SortFunc(list, func(a, b term) int { return strings.Compare(a.Slug, b.Slug) })
+
+	return list
+}
+
+// postSummary is a post in API responses.
+type postSummary struct {
+	Title   string    `json:"title"`
+	Path    string    `json:"path"`
+	URL     string    `json:"url"`
+	Summary string    `json:"summary,omitempty"`
+	Date    time.Time `json:"date"`
+	Section string    `json:"section,omitempty"`
+	Tags    []string  `json:"tags"`
+	Series  []string  `json:"series"`
+}
+
+func summarize(p post) postSummary {
+	return postSummary{
+		Title:   p.Title,
+		Path:    p.path(),
+		URL:     p.Permalink,
+		Summary: p.Summary,
+		Date:    p.Date,
+		Section: p.Section,
+		Tags:    nonNil(p.Tags),
+		Series:  nonNil(p.Series),
+	}
+}
+
+func summarizeAll(posts []post) []postSummary {
+	list := make([]postSummary, 0, len(posts))
+	for _, p := range posts {
+		list = append(list, summarize(p))
+	}
+
+	return list
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
+
+// writeTaxonomyJSON writes response, it changes only with deploys.
+func writeTaxonomyJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, http.StatusOK, v)
+}
+
+type tagsResponse struct {
+	Tags []term `json:"tags"`
+}
+
+func listTags(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeTaxonomyJSON(w, tagsResponse{Tags: sortedTerms(tx.tags)})
+	}
+}
+
+type tagResponse struct {
+	Tag   term          `json:"tag"`
+	Posts []postSummary `json:"posts"`
+}
+
+// getTag serves posts with the tag, newest first. Tag is matched by slug, so both /api/tags/Go and /api/tags/go work.
+func getTag(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := tx.tags[termSlug(r.PathValue("tag"))]
+		if !ok {
+			http.Error(w, "tag not found", http.StatusNotFound)
+			return
+		}
+
+		writeTaxonomyJSON(w, tagResponse{Tag: t.term, Posts: summarizeAll(t.posts)})
+	}
+}
+
+type seriesListResponse struct {
+	Series []term `json:"series"`
+}
+
+func listSeries(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeTaxonomyJSON(w, seriesListResponse{Series: sortedTerms(tx.series)})
+	}
+}
+
+type seriesPart struct {
+	Part int `json:"part"`
+	postSummary
+}
+
+type seriesResponse struct {
+	Series term         `json:"series"`
+	Parts  []seriesPart `json:"parts"`
+}
+
+// getSeries serves parts of the series in reading order, numbered from 1.
+func getSeries(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := tx.series[termSlug(r.PathValue("series"))]
+		if !ok {
+			http.Error(w, "series not found", http.StatusNotFound)
+			return
+		}
+
+		parts := make([]seriesPart, 0, len(s.posts))
+		for i, p := range s.posts {
+			parts = append(parts, seriesPart{Part: i + 1, postSummary: summarize(p)})
+		}
+
+		writeTaxonomyJSON(w, seriesResponse{Series: s.term, Parts: parts})
+	}
+}
+
+type postsResponse struct {
+	Posts      []postSummary `json:"posts"`
+	Page       int           `json:"page"`
+	PerPage    int           `json:"per_page"`
+	Total      int           `json:"total"`
+	TotalPages int           `json:"total_pages"`
+}
+
+// postsFilter selects posts by query parameters: tag (repeated tags must all match), series, section,
+// since and until (date or RFC 3339 time, inclusive).
+type postsFilter struct {
+	tags    []string
+	series  string
+	section string
+	since   time.Time
+	until   time.Time
+}
+
+func parsePostsFilter(query url.Values) (postsFilter, error) {
+	f := postsFilter{
+		series:  termSlug(query.Get("series")),
+		section: query.Get("section"),
+	}
+	for _, t := range query["tag"] {
+		f.tags = append(f.tags, termSlug(t))
+	}
+
+	var err error
+	if f.since, err = parseFilterTime(query.Get("since"), false); err != nil {
+		return postsFilter{}, fmt.Errorf("invalid since: %w", err)
+	}
+	if f.until, err = parseFilterTime(query.Get("until"), true); err != nil {
+		return postsFilter{}, fmt.Errorf("invalid until: %w", err)
+	}
+
+	return f, nil
+}
+
+// parseFilterTime parses date or RFC 3339 time. Date of the end of a range includes the whole day.
+func parseFilterTime(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func (f postsFilter) match(p post) bool {
+	if f.section != "" && p.Section != f.section {
+		return false
+	}
+	if f.series != "" && !slices.ContainsFunc(p.Series, func(s string) bool { return termSlug(s) == f.series }) {
+		return false
+	}
+	for _, tag := range f.tags {
+		if !slices.ContainsFunc(p.Tags, func(t string) bool { return termSlug(t) == tag }) {
+			return false
+		}
+	}
+	if !f.since.IsZero() && p.Date.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && p.Date.After(f.until) {
+		return false
+	}
+
+	return true
+}
+
+// listPosts serves filtered posts, newest first, paginated with page and per_page query parameters.
+// Links to the previous and next pages are in the Link header.
+func listPosts(tx *taxonomy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := parsePostsFilter(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := intParam(query, "page", 1)
+		if err != nil || page < 1 {
+			http.Error(w, "page must be a positive number", http.StatusBadRequest)
+			return
+		}
+		perPage, err := intParam(query, "per_page", defaultPostsPerPage)
+		if err != nil || perPage < 1 || perPage > maxPostsPerPage {
+			http.Error(w, fmt.Sprintf("per_page must be from 1 to %d", maxPostsPerPage), http.StatusBadRequest)
+			return
+		}
+
+		var matched []post
+		for _, p := range tx.posts {
+			if filter.match(p) {
+				matched = append(matched, p)
+			}
+		}
+
+		totalPages := int(math.Ceil(float64(len(matched)) / float64(perPage)))
+		start := min((page-1)*perPage, len(matched))
+		end := min(start+perPage, len(matched))
+
+		var links []string
+		if page > 1 && page <= totalPages {
+			links = append(links, pageLink(r.URL, page-1, "prev"))
+		}
+		if page < totalPages {
+			links = append(links, pageLink(r.URL, page+1, "next"))
+		}
+		if len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+
+		writeTaxonomyJSON(w, postsResponse{
+			Posts:      summarizeAll(matched[start:end]),
+			Page:       page,
+			PerPage:    perPage,
+			Total:      len(matched),
+			TotalPages: totalPages,
+		})
+	}
+}
+
+func intParam(query url.Values, name string, def int) (int, error) {
+	s := query.Get(name)
+	if s == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(s)
+}
+
+func pageLink(u *url.URL, page int, rel string) string {
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	link := url.URL{Path: u.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=%q", link.String(), rel)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTaxonomy(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 12, 0, 0, 0, time.UTC) }
+	tx := newTaxonomy([]post{
+		{Title: "Client Stream", Permalink: "https://getpid.dev/blog/http3/client-stream/", Date: day(28), Tags: []string{"HTTP3"}, Series: []string{"HTTP3"}, Section: "blog"},
+		{Title: "Writing HTTP/3 Server", Permalink: "https://getpid.dev/blog/http3/http3-server/", Date: day(8), Tags: []string{"HTTP3"}, Series: []string{"HTTP3"}, Section: "blog"},
+		{Title: "HTTP/3 Client", Permalink: "https://getpid.dev/blog/http3/http3-client/", Date: day(9), Tags: []string{"HTTP3", "Go"}, Series: []string{"HTTP3"}, Section: "blog"},
+		{Title: "Golden Tests", Permalink: "https://getpid.dev/blog/golden-tests/", Date: day(20), Tags: []string{"Go", "testing"}, Section: "blog"},
+		{Title: "Go links", Permalink: "https://getpid.dev/howto/go-links/", Date: day(1), Tags: []string{"Go"}, Section: "howto"},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", listTags(tx))
+	mux.HandleFunc("GET /api/tags/{tag}", getTag(tx))
+	mux.HandleFunc("GET /api/series/{series}", getSeries(tx))
+	mux.HandleFunc("GET /api/posts", listPosts(tx))
+
+	get := func(t *testing.T, target string, wantCode int, resp any) http.Header {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != wantCode {
+			t.Fatalf("expected status %d, got %d: %s", wantCode, rec.Code, rec.Body)
+		}
+		if resp != nil {
+			if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+				t.Fatalf("decode response: %s", err)
+			}
+		}
+		return rec.Header()
+	}
+
+	t.Run("tags", func(t *testing.T) {
+		var resp tagsResponse
+		get(t, "/api/tags", http.StatusOK, &resp)
+
+		want := []term{
+			{Name: "Go", Slug: "go", URL: "/tags/go/", Count: 3},
+			{Name: "HTTP3", Slug: "http3", URL: "/tags/http3/", Count: 3},
+			{Name: "testing", Slug: "testing", URL: "/tags/testing/", Count: 1},
+		}
+		if len(resp.Tags) != len(want) {
+			t.Fatalf("expected %v, got %v", want, resp.Tags)
+		}
+		for i := range want {
+			if resp.Tags[i] != want[i] {
+				t.Errorf("expected %v, got %v", want[i], resp.Tags[i])
+			}
+		}
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		var resp tagResponse
+		get(t, "/api/tags/Go", http.StatusOK, &resp)
+
+		if got := titles(resp.Posts); got != "Golden Tests, HTTP/3 Client, Go links" {
+			t.Fatalf("expected posts newest first, got %s", got)
+		}
+		get(t, "/api/tags/rust", http.StatusNotFound, nil)
+	})
+
+	t.Run("series", func(t *testing.T) {
+		var resp seriesResponse
+		get(t, "/api/series/http3", http.StatusOK, &resp)
+
+		if resp.Series.Count != 3 || len(resp.Parts) != 3 {
+			t.Fatalf("expected 3 parts, got %+v", resp)
+		}
+		for i, want := range []string{"Writing HTTP/3 Server", "HTTP/3 Client", "Client Stream"} {
+			if resp.Parts[i].Part != i+1 || resp.Parts[i].Title != want {
+				t.Errorf("expected part %d to be %s, got %d %s", i+1, want, resp.Parts[i].Part, resp.Parts[i].Title)
+			}
+		}
+		if resp.Parts[0].Path != "/blog/http3/http3-server/" {
+			t.Errorf("expected path of the post, got %s", resp.Parts[0].Path)
+		}
+		get(t, "/api/series/crdt", http.StatusNotFound, nil)
+	})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantTitles string
+		wantTotal  int
+		wantLink   string
+	}{
+		{name: "all", query: "", wantTitles: "Client Stream, Golden Tests, HTTP/3 Client, Writing HTTP/3 Server, Go links", wantTotal: 5},
+		{name: "tags", query: "tag=go&tag=http3", wantTitles: "HTTP/3 Client", wantTotal: 1},
+		{name: "series", query: "series=HTTP3", wantTitles: "Client Stream, HTTP/3 Client, Writing HTTP/3 Server", wantTotal: 3},
+		{name: "section", query: "section=howto", wantTitles: "Go links", wantTotal: 1},
+		{name: "dates", query: "since=2025-05-09&until=2025-05-20", wantTitles: "Golden Tests, HTTP/3 Client", wantTotal: 2},
+		{
+			name:       "first page",
+			query:      "per_page=2",
+			wantTitles: "Client Stream, Golden Tests",
+			wantTotal:  5,
+			wantLink:   `</api/posts?page=2&per_page=2>; rel="next"`,
+		},
+		{
+			name:       "middle page",
+			query:      "per_page=2&page=2",
+			wantTitles: "HTTP/3 Client, Writing HTTP/3 Server",
+			wantTotal:  5,
+			wantLink:   `</api/posts?page=1&per_page=2>; rel="prev", </api/posts?page=3&per_page=2>; rel="next"`,
+		},
+		{name: "page after the last", query: "page=3", wantTitles: "", wantTotal: 5},
+	}
+	for _, tt := range tests {
+		t.Run("posts "+tt.name, func(t *testing.T) {
+			var resp postsResponse
+			header := get(t, "/api/posts?"+tt.query, http.StatusOK, &resp)
+
+			if got := titles(resp.Posts); got != tt.wantTitles {
+				t.Errorf("expected %q, got %q", tt.wantTitles, got)
+			}
+			if resp.Total != tt.wantTotal {
+				t.Errorf("expected total %d, got %d", tt.wantTotal, resp.Total)
+			}
+			if got := header.Get("Link"); got != tt.wantLink {
+				t.Errorf("expected link %q, got %q", tt.wantLink, got)
+			}
+		})
+	}
+
+	for _, query := range []string{"page=0", "per_page=101", "page=first", "since=yesterday"} {
+		t.Run("posts invalid "+query, func(t *testing.T) {
+			get(t, "/api/posts?"+query, http.StatusBadRequest, nil)
+		})
+	}
+}
+
+func titles(posts []postSummary) string {
+	var s string
+	for i, p := range posts {
+		if i > 0 {
+			s += ", "
+		}
+		s += p.Title
+	}
+	return s
+}
+
+func TestTermSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Go", want: "go"},
+		{name: "Test Smell", want: "test-smell"},
+		{name: "CI/CD", want: "ci-cd"},
+		{name: "CI / CD", want: "ci-cd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := termSlug(tt.name); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	tx := newTaxonomy([]post{{Title: "Deploying", Permalink: "https://getpid.dev/blog/deploying/", Tags: []string{"CI/CD"}}})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags/{tag}", getTag(tx))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags/ci-cd", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for tag with slash, got %d: %s", rec.Code, rec.Body)
+	}
+}
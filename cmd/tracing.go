@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+
+	spanStatusError = 2
+)
+
+type traceID [16]byte
+
+func (id traceID) String() string { return hex.EncodeToString(id[:]) }
+
+type spanID [8]byte
+
+func (id spanID) String() string { return hex.EncodeToString(id[:]) }
+
+// spanContext is a W3C Trace Context: https://www.w3.org/TR/trace-context/.
+type spanContext struct {
+	TraceID traceID
+	SpanID  spanID
+	Sampled bool
+}
+
+// traceparent formats span context as traceparent header value.
+func (sc spanContext) traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// parseTraceparent parses traceparent header value, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(s string) (spanContext, error) {
+	var sc spanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return sc, fmt.Errorf("invalid traceparent version %q", parts[0])
+	}
+	if version[0] == 0 && len(parts) != 4 { // future versions may append fields
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+
+	// IDs must be lowercase hex and not all zeros
+	if len(parts[1]) != 32 || !isLowerHex(parts[1]) || strings.Trim(parts[1], "0") == "" {
+		return sc, fmt.Errorf("invalid trace ID %q", parts[1])
+	}
+	_, _ = hex.Decode(sc.TraceID[:], []byte(parts[1]))
+
+	if len(parts[2]) != 16 || !isLowerHex(parts[2]) || strings.Trim(parts[2], "0") == "" {
+		return sc, fmt.Errorf("invalid parent ID %q", parts[2])
+	}
+	_, _ = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+
+	if len(parts[3]) != 2 || !isLowerHex(parts[3]) {
+		return sc, fmt.Errorf("invalid trace flags %q", parts[3])
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, fmt.Errorf("invalid trace flags %q", parts[3])
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+type attribute struct {
+	Key   string
+	Value any
+}
+
+// span is a single timed operation of a trace.
+type span struct {
+	tracer *tracer
+
+	Context    spanContext
+	Parent     spanID
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes []attribute
+	Status     int
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// SetAttributes adds attributes to the span.
+func (s *span) SetAttributes(attrs ...attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+// SetStatus sets span status.
+func (s *span) SetStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Status = status
+}
+
+// Finish ends the span and queues it for export.
+func (s *span) Finish() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	if s.Context.Sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// spanExporter sends finished spans to a tracing backend.
+type spanExporter interface {
+	ExportSpans(ctx context.Context, spans []*span) error
+}
+
+// tracer creates spans and exports them in batches.
+type tracer struct {
+	exporter  spanExporter
+	batchSize int
+	interval  time.Duration
+
+	queue    chan *span
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	shutdown sync.Once
+}
+
+func newTracer(exporter spanExporter) *tracer {
+	t := &tracer{
+		exporter:  exporter,
+		batchSize: 512,
+		interval:  5 * time.Second,
+		queue:     make(chan *span, 2048),
+		flush:     make(chan chan struct{}),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	go t.run()
+
+	return t
+}
+
+// Start starts a new span as a child of the span in ctx, or a new trace if there is none.
+func (t *tracer) Start(ctx context.Context, name string, kind int) (context.Context, *span) {
+	return t.start(ctx, name, kind, spanFromContext(ctx).Context)
+}
+
+func (t *tracer) start(ctx context.Context, name string, kind int, parent spanContext) (context.Context, *span) {
+	s := &span{
+		tracer: t,
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+	}
+
+	if parent.TraceID == (traceID{}) {
+		s.Context = spanContext{TraceID: randomTraceID(), Sampled: true}
+	} else {
+		s.Context = parent
+		s.Parent = parent.SpanID
+	}
+	s.Context.SpanID = randomSpanID()
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (t *tracer) enqueue(s *span) {
+	if t.exporter == nil {
+		return
+	}
+
+	select {
+	case t.queue <- s:
+	default:
+		slog.Warn("span queue is full, dropping span", "name", s.Name)
+	}
+}
+
+func (t *tracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	batch := make([]*span, 0, t.batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			slog.Error("failed to export spans", "error", err, "count", len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	drain := func() {
+		for {
+			select {
+			case s := <-t.queue:
+				batch = append(batch, s)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= t.batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-t.flush:
+			drain()
+			export()
+			close(flushed)
+		case <-t.done:
+			drain()
+			export()
+			return
+		}
+	}
+}
+
+// Flush exports all finished spans.
+func (t *tracer) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case t.flush <- flushed:
+	case <-t.stopped:
+		return nil // spans were exported on shutdown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports remaining spans and stops the tracer. It is safe to call it more than once.
+func (t *tracer) Shutdown(ctx context.Context) error {
+	t.shutdown.Do(func() { close(t.done) })
+
+	select {
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type spanKey struct{}
+
+// spanFromContext returns current span, or an empty span if there is none.
+func spanFromContext(ctx context.Context) *span {
+	if s, ok := ctx.Value(spanKey{}).(*span); ok {
+		return s
+	}
+
+	return &span{}
+}
+
+type requestIDKey struct{}
+
+// requestIDFromContext returns request ID assigned by TracingMiddleware.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func randomTraceID() traceID {
+	var id traceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func randomSpanID() spanID {
+	var id spanID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// TracingMiddleware continues the trace from traceparent header or starts a new one,
+// and assigns a request ID, which is echoed in X-Request-ID header.
+func TracingMiddleware(t *tracer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = randomTraceID().String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		parent, err := parseTraceparent(r.Header.Get("traceparent"))
+		if err != nil {
+			parent = spanContext{}
+		}
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		ctx, s := t.start(ctx, r.Method+" "+r.URL.Path, spanKindServer, parent)
+		defer s.Finish()
+
+		s.SetAttributes(
+			attribute{"http.request.method", r.Method},
+			attribute{"url.path", r.URL.Path},
+			attribute{"server.address", r.Host},
+			attribute{"user_agent.original", r.UserAgent()},
+			attribute{"http.request.header.x-request-id", requestID},
+		)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		s.SetAttributes(attribute{"http.response.status_code", rec.status})
+		if rec.status >= 500 {
+			s.SetStatus(spanStatusError)
+		}
+
+		slog.DebugContext(ctx, "request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(s.Start),
+		)
+	})
+}
+
+// SpanMiddleware records a span named name around the next handler.
+func SpanMiddleware(t *tracer, name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := t.Start(r.Context(), name, spanKindInternal)
+		defer s.Finish()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e { // printable ASCII without spaces
+			return false
+		}
+	}
+
+	return true
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// traceLogHandler adds trace_id, span_id and request_id to log records.
+type traceLogHandler struct {
+	slog.Handler
+}
+
+func newTraceLogHandler(h slog.Handler) traceLogHandler {
+	return traceLogHandler{Handler: h}
+}
+
+func (h traceLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if s, ok := ctx.Value(spanKey{}).(*span); ok {
+		r.AddAttrs(
+			slog.String("trace_id", s.Context.TraceID.String()),
+			slog.String("span_id", s.Context.SpanID.String()),
+		)
+	}
+	if id := requestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceLogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceLogHandler) WithGroup(name string) slog.Handler {
+	return traceLogHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const tracerScope = "github.com/dmksnnk/blog/cmd"
+
+// stdoutExporter writes spans as JSON lines.
+type stdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newStdoutExporter(w io.Writer) *stdoutExporter {
+	return &stdoutExporter{w: w}
+}
+
+func (e *stdoutExporter) ExportSpans(_ context.Context, spans []*span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		if err := enc.Encode(otlpSpanFrom(s)); err != nil {
+			return fmt.Errorf("encode span: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// otlpExporter sends spans to OpenTelemetry collector using OTLP/HTTP with JSON encoding.
+// See https://opentelemetry.io/docs/specs/otlp/#otlphttp.
+type otlpExporter struct {
+	client      *http.Client
+	endpoint    string
+	serviceName string
+}
+
+// newOTLPExporter creates OTLP exporter, endpoint is the base URL of the collector,
+// e.g. http://localhost:4318.
+func newOTLPExporter(client *http.Client, endpoint, serviceName string) *otlpExporter {
+	return &otlpExporter{
+		client:      client,
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+	}
+}
+
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []*span) error {
+	req := otlpTraceRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{otlpAttributeFrom(attribute{"service.name", e.serviceName})},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: tracerScope},
+				Spans: make([]otlpSpan, 0, len(spans)),
+			}},
+		}},
+	}
+	for _, s := range spans {
+		req.ResourceSpans[0].ScopeSpans[0].Spans = append(req.ResourceSpans[0].ScopeSpans[0].Spans, otlpSpanFrom(s))
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal spans: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("send spans: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status from collector: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// OTLP JSON encoding, see https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto.
+// Trace and span IDs are hex-encoded, 64-bit integers are strings.
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func otlpSpanFrom(s *span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := otlpSpan{
+		TraceID:           s.Context.TraceID.String(),
+		SpanID:            s.Context.SpanID.String(),
+		Name:              s.Name,
+		Kind:              s.Kind,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.Status},
+	}
+	if s.Parent != (spanID{}) {
+		out.ParentSpanID = s.Parent.String()
+	}
+	for _, a := range s.Attributes {
+		out.Attributes = append(out.Attributes, otlpAttributeFrom(a))
+	}
+
+	return out
+}
+
+func otlpAttributeFrom(a attribute) otlpAttribute {
+	var v otlpAnyValue
+	switch value := a.Value.(type) {
+	case int:
+		s := strconv.Itoa(value)
+		v.IntValue = &s
+	case bool:
+		v.BoolValue = &value
+	case string:
+		v.StringValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+
+	return otlpAttribute{Key: a.Key, Value: v}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		sampled bool
+		wantErr bool
+	}{
+		{name: "sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sampled: true},
+		{name: "not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "future version", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", sampled: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "invalid version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "extra fields in version 00", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{name: "uppercase", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero trace ID", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero parent ID", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{name: "short parent ID", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba9-01", wantErr: true},
+		{name: "long flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01zz", wantErr: true},
+		{name: "short flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", wantErr: true},
+		{name: "uppercase flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0A", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := parseTraceparent(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", sc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse traceparent: %s", err)
+			}
+
+			if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("unexpected trace ID %s", sc.TraceID)
+			}
+			if sc.SpanID.String() != "00f067aa0ba902b7" {
+				t.Errorf("unexpected span ID %s", sc.SpanID)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("expected sampled %t, got %t", tt.sampled, sc.Sampled)
+			}
+		})
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	collector := newCollectorStub(t)
+	tr := newTracer(newOTLPExporter(collector.srv.Client(), collector.srv.URL, "blog-test"))
+
+	var logs bytes.Buffer
+	logger := slog.New(newTraceLogHandler(slog.NewJSONHandler(&logs, nil)))
+
+	handler := TracingMiddleware(tr, SpanMiddleware(tr, "file", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "serving file")
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/blog/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("expected request ID req-1, got %q", got)
+	}
+
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown tracer: %s", err)
+	}
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown tracer again: %s", err)
+	}
+
+	spans := collector.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	file, server := spans[0], spans[1]
+	if server.Name != "GET /blog/" || server.Kind != spanKindServer {
+		t.Errorf("unexpected server span: %+v", server)
+	}
+	if server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected server span parent from traceparent, got %q", server.ParentSpanID)
+	}
+	if file.Name != "file" || file.ParentSpanID != server.SpanID {
+		t.Errorf("expected file span to be a child of server span: %+v", file)
+	}
+	for _, s := range spans {
+		if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %q has unexpected trace ID %s", s.Name, s.TraceID)
+		}
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %s", err)
+	}
+	if record["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || record["span_id"] != file.SpanID || record["request_id"] != "req-1" {
+		t.Errorf("log record has no trace context: %s", logs.String())
+	}
+}
+
+func TestTracingMiddlewareNewTrace(t *testing.T) {
+	tr := newTracer(nil)
+	defer tr.Shutdown(context.Background())
+
+	var sc spanContext
+	handler := TracingMiddleware(tr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc = spanFromContext(r.Context()).Context
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "garbage")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if sc.TraceID == (traceID{}) || !sc.Sampled {
+		t.Errorf("expected new sampled trace, got %s", sc.traceparent())
+	}
+	if id := rec.Header().Get("X-Request-ID"); len(id) != 32 {
+		t.Errorf("expected generated request ID, got %q", id)
+	}
+}
+
+// collectorStub is a minimal OTLP/HTTP collector.
+type collectorStub struct {
+	srv *httptest.Server
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func newCollectorStub(t *testing.T) *collectorStub {
+	c := &collectorStub{}
+	c.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		var req otlpTraceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(c.srv.Close)
+
+	return c
+}
+
+func (c *collectorStub) Spans() []otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.spans
+}
@@ -28,6 +28,7 @@ func main() {
 		os.Exit(1)
 	}
 
+	// snippet:start template
 	template := x509.Certificate{
 		SerialNumber: serialNumber,
 		NotBefore:    time.Now(),
@@ -41,6 +42,7 @@ func main() {
 		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
 		DNSNames:    []string{"localhost"},
 	}
+	// snippet:end template
 
 	// Parent is equal to template, which means it is self-signed.
 	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
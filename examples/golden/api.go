@@ -2,17 +2,59 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+const (
+	// MaxBodySize is the maximum size of request body in bytes.
+	MaxBodySize = 1 << 10
+	// MaxNameLength is the maximum length of name in characters.
+	MaxNameLength = 100
+)
+
+const problemContentType = "application/problem+json"
+
+// Types of problems, relative URIs are resolved against the API URL.
+const (
+	ProblemInvalidJSON     = "/problems/invalid-json"
+	ProblemBodyTooLarge    = "/problems/body-too-large"
+	ProblemValidationError = "/problems/validation-error"
+)
+
+// snippet:start types
 type GreetRequest struct {
 	Name string `json:"name"`
 }
+
 type GreetResponse struct {
 	Message string `json:"message"`
 }
 
+// snippet:end types
+
+// Problem is an error response, see RFC 9457.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Errors are problems of the request fields.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a problem of a request field.
+type FieldError struct {
+	// Pointer is JSON Pointer to the field, like /name, it is empty for the whole body.
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
 type API struct{}
 
 func NewAPI() API {
@@ -21,8 +63,13 @@ func NewAPI() API {
 
 func (a API) Greet(w http.ResponseWriter, r *http.Request) {
 	var req GreetRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if problem := decodeRequest(w, r, &req); problem != nil {
+		writeProblem(w, *problem)
+		return
+	}
+
+	if errs := req.validate(); len(errs) > 0 {
+		writeProblem(w, validationProblem(errs...))
 		return
 	}
 
@@ -37,6 +84,117 @@ func (a API) Greet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (r GreetRequest) validate() []FieldError {
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		return []FieldError{{Pointer: "/name", Detail: "name is required"}}
+	case utf8.RuneCountInString(r.Name) > MaxNameLength:
+		return []FieldError{{Pointer: "/name", Detail: fmt.Sprintf("name must be at most %d characters", MaxNameLength)}}
+	}
+
+	return nil
+}
+
+// decodeRequest decodes JSON body of at most MaxBodySize into v, rejecting unknown fields.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) *Problem {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		// only one JSON value is allowed
+		if _, err = dec.Token(); errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("unexpected data after JSON value")
+		}
+	}
+
+	var (
+		maxBytesErr  *http.MaxBytesError
+		syntaxErr    *json.SyntaxError
+		typeErr      *json.UnmarshalTypeError
+		unknownField string
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &Problem{
+			Type:   ProblemBodyTooLarge,
+			Title:  "Request body is too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request body must be at most %d bytes", maxBytesErr.Limit),
+		}
+	case errors.Is(err, io.EOF):
+		return invalidJSONProblem("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return invalidJSONProblem("request body is truncated")
+	case errors.As(err, &syntaxErr):
+		return invalidJSONProblem(fmt.Sprintf("%s at offset %d", syntaxErr, syntaxErr.Offset))
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		// the body itself has the wrong type, like [] or "x", pointer "" refers to the whole document
+		p := validationProblem(FieldError{
+			Pointer: "",
+			Detail:  "request body must be a JSON object, got " + typeErr.Value,
+		})
+		return &p
+	case errors.As(err, &typeErr):
+		p := validationProblem(FieldError{
+			Pointer: "/" + strings.ReplaceAll(typeErr.Field, ".", "/"),
+			Detail:  fmt.Sprintf("must be %s, got %s", typeErr.Type, typeErr.Value),
+		})
+		return &p
+	case unknownFieldName(err, &unknownField):
+		p := validationProblem(FieldError{Pointer: "/" + unknownField, Detail: "unknown field"})
+		return &p
+	default:
+		return invalidJSONProblem(err.Error())
+	}
+}
+
+// unknownFieldName extracts field name from error of json.Decoder with disallowed unknown fields.
+// The decoder has no type for the error.
+func unknownFieldName(err error, name *string) bool {
+	quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return false
+	}
+
+	field, uerr := strconv.Unquote(quoted)
+	if uerr != nil {
+		return false
+	}
+
+	*name = field
+	return true
+}
+
+func invalidJSONProblem(detail string) *Problem {
+	return &Problem{
+		Type:   ProblemInvalidJSON,
+		Title:  "Request body is not valid JSON",
+		Status: http.StatusBadRequest,
+		Detail: detail,
+	}
+}
+
+func validationProblem(errs ...FieldError) Problem {
+	return Problem{
+		Type:   ProblemValidationError,
+		Title:  "Request is invalid",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "see errors for the invalid fields",
+		Errors: errs,
+	}
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p) // status is sent already, nothing to report
+}
+
 func NewRouter(api API) *http.ServeMux {
 	mux := http.NewServeMux()
 
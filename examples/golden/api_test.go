@@ -1,17 +1,21 @@
 package api_test
 
 import (
-	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"testing"
 
 	api "example.com/golden"
 	"example.com/golden/golden"
-	"github.com/google/go-cmp/cmp"
 )
 
+func TestMain(m *testing.M) {
+	os.Exit(golden.Run(m))
+}
+
 func TestGreetAPI(t *testing.T) {
 	a := api.NewAPI()
 	router := api.NewRouter(a)
@@ -20,6 +24,8 @@ func TestGreetAPI(t *testing.T) {
 	defer srv.Close()
 
 	client := srv.Client()
+	// snippet:start test
+	// reading test request
 	resp, err := client.Post(srv.URL+"/greet", "application/json", golden.Open(t, "request.json"))
 	if err != nil {
 		t.Fatalf("make request: %s", err)
@@ -30,7 +36,9 @@ func TestGreetAPI(t *testing.T) {
 		t.Fatalf("expected status 200, got %d", resp.StatusCode)
 	}
 
+	// asserting response
 	assertResponse(t, resp, "response.json")
+	// snippet:end test
 }
 
 func assertResponse(t *testing.T, resp *http.Response, fixturePath string) {
@@ -41,21 +49,39 @@ func assertResponse(t *testing.T, resp *http.Response, fixturePath string) {
 		t.Fatalf("read response body: %v", err)
 	}
 
-	assertEqualJSON(t, golden.ReadBytes(t, fixturePath), body)
+	golden.AssertJSON(t, fixturePath, body)
 }
 
-func assertEqualJSON(t *testing.T, wantJSON, gotJSON []byte) {
-	t.Helper()
+func TestGreetAPICases(t *testing.T) {
+	srv := httptest.NewServer(api.NewRouter(api.NewAPI()))
+	t.Cleanup(srv.Close) // cases run in parallel after the test function returns
 
-	var want, got any
-	if err := json.Unmarshal(wantJSON, &want); err != nil {
-		t.Fatalf("unmarshal want JSON: %v", err)
-	}
-	if err := json.Unmarshal(gotJSON, &got); err != nil {
-		t.Fatalf("unmarshal got JSON: %v", err)
-	}
+	golden.Cases(t, "cases", func(t *testing.T, c golden.Case) {
+		resp, err := srv.Client().Post(srv.URL+"/greet", "application/json", c.Open("request.json"))
+		if err != nil {
+			t.Fatalf("make request: %s", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read response body: %v", err)
+		}
+
+		c.Assert("status.txt", []byte(strconv.Itoa(resp.StatusCode)+"\n"))
+		c.AssertJSON("response.json", body)
+	})
+}
+
+func TestGreetAPITranscripts(t *testing.T) {
+	router := api.NewRouter(api.NewAPI())
+	srv := httptest.NewServer(router)
+	defer srv.Close()
 
-	if diff := cmp.Diff(want, got); diff != "" {
-		t.Errorf("JSON mismatch (-want +got):\n%s", diff)
+	for _, name := range []string{"greet.http", "invalid_json.http", "missing_name.http", "wrong_method.http"} {
+		t.Run(name, func(t *testing.T) {
+			golden.AssertHTTP(t, "http/"+name, router)
+			golden.AssertHTTPServer(t, "http/"+name, srv)
+		})
 	}
 }
@@ -2,4 +2,8 @@ module example.com/golden
 
 go 1.24.3
 
-require github.com/google/go-cmp v0.7.0
+require (
+	github.com/BurntSushi/toml v1.5.0
+	golang.org/x/net v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
+)
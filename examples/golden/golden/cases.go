@@ -0,0 +1,70 @@
+package golden
+
+import (
+	"path"
+	"testing"
+)
+
+// Case is a test case discovered by Cases. Its fixtures are the inputs and golden files of the case directory.
+type Case struct {
+	*Fixtures
+	// Name is the name of the case directory.
+	Name string
+}
+
+// Cases runs fn for every directory in ./testdata/<dir>/, see Fixtures.Cases.
+func Cases(t *testing.T, dir string, fn func(t *testing.T, c Case)) {
+	t.Helper()
+	New(t).Cases(dir, fn)
+}
+
+// Cases runs fn for every directory in dir as a parallel subtest named after the directory.
+// Each case reads inputs from its directory, like request.json, and compares outputs with golden files there,
+// like response.json. In update mode, missing golden files are written, so a new case needs only the inputs:
+//
+//	testdata/cases/
+//	├── empty_name/
+//	│   ├── request.json
+//	│   ├── response.json
+//	│   └── status.txt
+//	└── john_doe/
+//	    └── request.json
+//
+// Fixtures must be created with *testing.T, as cases are its subtests.
+func (f *Fixtures) Cases(dir string, fn func(t *testing.T, c Case)) {
+	f.t.Helper()
+
+	parent, ok := f.t.(*testing.T)
+	if !ok {
+		f.t.Fatalf("cases run as subtests, fixtures must be created with *testing.T, got %T", f.t)
+	}
+
+	root := f.name(dir)
+	entries, err := f.readDir(dir)
+	if err != nil {
+		f.t.Fatalf("read cases: %s", err)
+	}
+
+	var found bool
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		found = true
+		name := e.Name()
+		parent.Run(name, func(t *testing.T) {
+			t.Parallel()
+			trackSkip(t)
+
+			fn(t, Case{
+				Fixtures: &Fixtures{t: t, fsys: f.fsys, dir: path.Join(root, name)},
+				Name:     name,
+			})
+		})
+	}
+
+	if !found {
+		f.t.Fatalf("no cases in %s", f.Path(dir))
+	}
+}
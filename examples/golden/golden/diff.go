@@ -0,0 +1,255 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around changes.
+	diffContext = 3
+	// maxDiffLines limits the diff printed in test output, the rest is in the .actual file.
+	maxDiffLines = 200
+	// maxDiffCells limits memory of the line matching, bigger inputs are shown as fully replaced.
+	maxDiffCells = 1 << 22
+)
+
+// diffBytes returns unified diff of want and got. Binary data is compared as hex dumps.
+func diffBytes(want, got []byte) string {
+	if isBinary(want) || isBinary(got) {
+		return unifiedDiff(hexDump(want), hexDump(got), false)
+	}
+
+	return unifiedDiff(splitLines(string(want)), splitLines(string(got)), true)
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
+
+// splitLines splits text into lines, keeping line endings, so changes of line endings are visible.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// hexDump formats data like hexdump -C, one line per 16 bytes.
+func hexDump(data []byte) []string {
+	var lines []string
+	for off := 0; off < len(data); off += 16 {
+		row := data[off:min(off+16, len(data))]
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%08x  ", off)
+		for i := range 16 {
+			if i < len(row) {
+				fmt.Fprintf(&b, "%02x ", row[i])
+			} else {
+				b.WriteString("   ")
+			}
+			if i == 7 {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(" |")
+		for _, c := range row {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			b.WriteByte(c)
+		}
+		b.WriteString("|\n")
+
+		lines = append(lines, b.String())
+	}
+
+	return lines
+}
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines returns edits turning a into b, using the longest common subsequence of lines.
+func diffLines(a, b []string) []edit {
+	var prefix, suffix []edit
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, edit{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]edit{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	edits := prefix
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			edits = append(edits, edit{'-', l})
+		}
+		for _, l := range b {
+			edits = append(edits, edit{'+', l})
+		}
+		return append(edits, suffix...)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{'+', b[j]})
+	}
+
+	return append(edits, suffix...)
+}
+
+// unifiedDiff formats differences of want and got lines as unified diff with context.
+// With visualize, whitespace and line endings of changed lines are made visible.
+func unifiedDiff(want, got []string, visualize bool) string {
+	edits := diffLines(want, got)
+
+	var changes []int
+	for i, e := range edits {
+		if e.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("--- want\n+++ got\n")
+
+	for k := 0; k < len(changes); {
+		// group changes which are close enough to share context
+		last := k
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext {
+			last++
+		}
+		start := max(changes[k]-diffContext, 0)
+		end := min(changes[last]+diffContext+1, len(edits))
+
+		wantStart, gotStart := 1, 1
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				wantStart++
+			}
+			if e.op != '-' {
+				gotStart++
+			}
+		}
+		var wantCount, gotCount int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				wantCount++
+			}
+			if e.op != '-' {
+				gotCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(wantStart, wantCount), hunkRange(gotStart, gotCount))
+
+		for _, e := range edits[start:end] {
+			line := e.line
+			if e.op != ' ' && visualize {
+				line = visualizeLine(line)
+			} else {
+				line = strings.TrimSuffix(line, "\n")
+			}
+			b.WriteByte(e.op)
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+
+		k = last + 1
+	}
+
+	return b.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start-- // empty range starts at the line before, as in diff -u
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+var trailingSpaceReplacer = strings.NewReplacer(" ", "·", "\t", "→")
+
+// visualizeLine shows tabs as →, trailing spaces as ·, carriage returns as ␍ and a missing final new line like diff does.
+func visualizeLine(line string) string {
+	line, hasNewline := strings.CutSuffix(line, "\n")
+	line, hasCR := strings.CutSuffix(line, "\r")
+
+	trimmed := strings.TrimRight(line, " \t")
+	s := strings.ReplaceAll(trimmed, "\t", "→") + trailingSpaceReplacer.Replace(line[len(trimmed):])
+	if hasCR {
+		s += "␍"
+	}
+	if !hasNewline {
+		s += "\n\\ No newline at end of file"
+	}
+
+	return s
+}
+
+// truncateDiff keeps the first maxDiffLines lines of diff, it returns the number of cut lines.
+func truncateDiff(diff string) (string, int) {
+	lines := strings.SplitAfter(diff, "\n")
+	if len(lines) <= maxDiffLines {
+		return diff, 0
+	}
+
+	rest := len(lines) - maxDiffLines
+	if lines[len(lines)-1] == "" {
+		rest--
+	}
+
+	return strings.Join(lines[:maxDiffLines], ""), rest
+}
@@ -0,0 +1,438 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// Comparer compares output of a format with the golden file semantically, so differences
+// insignificant for the format, like formatting, don't fail tests.
+type Comparer interface {
+	// Compare returns description of differences of got from want, it is empty if they are equal.
+	// It returns an error if want or got is not valid for the format.
+	Compare(want, got []byte) (string, error)
+}
+
+// ComparerFunc is a function implementing Comparer.
+type ComparerFunc func(want, got []byte) (string, error)
+
+// Compare calls fn(want, got).
+func (fn ComparerFunc) Compare(want, got []byte) (string, error) {
+	return fn(want, got)
+}
+
+var (
+	comparersMu sync.RWMutex
+	comparers   = map[string]Comparer{
+		".json": JSON(),
+		".yaml": YAML(),
+		".yml":  YAML(),
+		".toml": TOML(),
+		".xml":  XML(),
+		".html": HTML(),
+		".htm":  HTML(),
+		".csv":  CSV(),
+	}
+)
+
+// Register sets comparer of golden files with the extension, like ".yaml", used by AssertFormat.
+// It replaces the comparer registered before, including the built-in ones.
+func Register(ext string, c Comparer) {
+	comparersMu.Lock()
+	defer comparersMu.Unlock()
+
+	comparers[strings.ToLower(ext)] = c
+}
+
+func comparerFor(name string) (Comparer, bool) {
+	comparersMu.RLock()
+	defer comparersMu.RUnlock()
+
+	c, ok := comparers[strings.ToLower(path.Ext(name))]
+	return c, ok
+}
+
+// AssertFormat compares got with the golden file in ./testdata/ by its format, see Fixtures.AssertFormat.
+func AssertFormat(t testing.TB, name string, got []byte) {
+	t.Helper()
+	New(t).AssertFormat(name, got)
+}
+
+// AssertFormat compares got with the golden file using Comparer registered for extension of the file.
+// Built-in comparers handle JSON, YAML, TOML, XML, HTML and CSV.
+func (f *Fixtures) AssertFormat(name string, got []byte) {
+	f.t.Helper()
+
+	c, ok := comparerFor(name)
+	if !ok {
+		f.t.Fatalf("no comparer registered for %s, use Register or Assert", f.Path(name))
+	}
+
+	f.AssertWith(name, got, c)
+}
+
+// AssertWith compares got with the golden file using c.
+// In update mode, it writes got into the golden file, if c reports differences.
+func (f *Fixtures) AssertWith(name string, got []byte, c Comparer) {
+	f.t.Helper()
+
+	f.removeArtifact(f.actualPath(name))
+
+	if updating() {
+		if want, err := f.readFile(name); err == nil {
+			if diff, err := c.Compare(want, got); err == nil && diff == "" {
+				return
+			}
+		}
+
+		f.write(name, got)
+		return
+	}
+
+	diff, err := c.Compare(f.readGolden(name), got)
+	if err != nil {
+		f.t.Fatalf("compare %s: %s", f.Path(name), err)
+	}
+	if diff != "" {
+		f.mismatch(name, diff, got)
+	}
+}
+
+// JSON compares JSON values, see AssertJSON.
+func JSON(opts ...JSONOption) Comparer {
+	return ComparerFunc(func(want, got []byte) (string, error) {
+		o, err := newJSONOptions(opts)
+		if err != nil {
+			return "", err
+		}
+
+		wantValue, err := parseJSON(want)
+		if err != nil {
+			return "", fmt.Errorf("parse want: %w", err)
+		}
+		gotValue, err := parseJSON(got)
+		if err != nil {
+			return "", fmt.Errorf("parse got: %w", err)
+		}
+
+		return joinDiffs(o.diff(nil, o.redact(nil, wantValue), o.redact(nil, gotValue))), nil
+	})
+}
+
+// YAML compares YAML documents as structures, differences are reported with JSONPath.
+func YAML(opts ...JSONOption) Comparer {
+	return structComparer(func(data []byte) (any, error) {
+		var v any
+		err := yaml.Unmarshal(data, &v)
+		return v, err
+	}, opts)
+}
+
+// TOML compares TOML documents as structures, differences are reported with JSONPath.
+func TOML(opts ...JSONOption) Comparer {
+	return structComparer(func(data []byte) (any, error) {
+		var v map[string]any
+		_, err := toml.Decode(string(data), &v)
+		return v, err
+	}, opts)
+}
+
+// structComparer compares decoded documents by converting them to JSON values,
+// so options of JSON comparison apply to them.
+func structComparer(decode func([]byte) (any, error), opts []JSONOption) Comparer {
+	return ComparerFunc(func(want, got []byte) (string, error) {
+		o, err := newJSONOptions(opts)
+		if err != nil {
+			return "", err
+		}
+
+		wantValue, err := decode(want)
+		if err != nil {
+			return "", fmt.Errorf("parse want: %w", err)
+		}
+		gotValue, err := decode(got)
+		if err != nil {
+			return "", fmt.Errorf("parse got: %w", err)
+		}
+
+		w, g := toJSONValue(wantValue), toJSONValue(gotValue)
+		return joinDiffs(o.diff(nil, o.redact(nil, w), o.redact(nil, g))), nil
+	})
+}
+
+func joinDiffs(diffs []string) string {
+	if len(diffs) == 0 {
+		return ""
+	}
+
+	return strings.Join(diffs, "\n") + "\n"
+}
+
+// toJSONValue converts decoded YAML or TOML into values of parseJSON.
+func toJSONValue(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case bool:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int, int64, uint64, float64:
+		return json.Number(fmt.Sprint(v))
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		obj := &jsonObject{values: make(map[string]any, rv.Len())}
+		for _, k := range rv.MapKeys() {
+			key := fmt.Sprint(k.Interface())
+			obj.keys = append(obj.keys, key)
+			obj.values[key] = toJSONValue(rv.MapIndex(k).Interface())
+		}
+		slices.Sort(obj.keys)
+		return obj
+	case reflect.Slice, reflect.Array:
+		arr := make([]any, rv.Len())
+		for i := range arr {
+			arr[i] = toJSONValue(rv.Index(i).Interface())
+		}
+		return arr
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// XML compares XML documents as trees, ignoring attribute order, comments and whitespace around text.
+// Differences are shown as unified diff of the canonical documents.
+func XML() Comparer {
+	return treeComparer(func(r io.Reader) *xml.Decoder {
+		return xml.NewDecoder(r)
+	})
+}
+
+// HTML compares HTML documents as trees like XML, also collapsing whitespace in text outside of <pre>.
+// Documents are parsed as browsers do, so omitted end tags, like of <p> and <li>, unquoted attributes
+// and scripts with "<" in them are fine. Both documents get <html>, <head> and <body> if they don't have them.
+func HTML() Comparer {
+	return ComparerFunc(func(want, got []byte) (string, error) {
+		w, err := html.Parse(bytes.NewReader(want))
+		if err != nil {
+			return "", fmt.Errorf("parse want: %w", err)
+		}
+		g, err := html.Parse(bytes.NewReader(got))
+		if err != nil {
+			return "", fmt.Errorf("parse got: %w", err)
+		}
+
+		return unifiedDiff(canonicalHTML(nil, w, 0, false), canonicalHTML(nil, g, 0, false), true), nil
+	})
+}
+
+// canonicalHTML appends lines of the node like canonicalTree, skipping doctype and comments.
+func canonicalHTML(lines []string, n *html.Node, depth int, pre bool) []string {
+	indent := strings.Repeat("  ", depth)
+	switch n.Type {
+	case html.ElementNode:
+		attrs := make([]string, 0, len(n.Attr))
+		for _, a := range n.Attr {
+			name := a.Key
+			if a.Namespace != "" {
+				name = a.Namespace + ":" + a.Key
+			}
+			attrs = append(attrs, fmt.Sprintf("%s=%q", name, a.Val))
+		}
+		slices.Sort(attrs)
+
+		line := indent + "<" + n.Data
+		if len(attrs) > 0 {
+			line += " " + strings.Join(attrs, " ")
+		}
+		lines = append(lines, line+">\n")
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			lines = canonicalHTML(lines, c, depth+1, pre || n.Data == "pre")
+		}
+		return append(lines, indent+"</"+n.Data+">\n")
+	case html.TextNode:
+		text := strconv.Quote(n.Data) // whitespace is significant in <pre>
+		if !pre {
+			text = strings.Join(strings.Fields(n.Data), " ")
+		}
+		if text != "" {
+			lines = append(lines, indent+text+"\n")
+		}
+		return lines
+	case html.DocumentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			lines = canonicalHTML(lines, c, depth, pre)
+		}
+		return lines
+	default:
+		return lines
+	}
+}
+
+func treeComparer(newDecoder func(io.Reader) *xml.Decoder) Comparer {
+	return ComparerFunc(func(want, got []byte) (string, error) {
+		w, err := canonicalTree(newDecoder(bytes.NewReader(want)))
+		if err != nil {
+			return "", fmt.Errorf("parse want: %w", err)
+		}
+		g, err := canonicalTree(newDecoder(bytes.NewReader(got)))
+		if err != nil {
+			return "", fmt.Errorf("parse got: %w", err)
+		}
+
+		return unifiedDiff(w, g, true), nil
+	})
+}
+
+// canonicalTree returns lines of the document with one element, sorted attributes, or text per line,
+// indented by depth.
+func canonicalTree(dec *xml.Decoder) ([]string, error) {
+	var (
+		lines []string
+		depth int
+	)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		indent := strings.Repeat("  ", depth)
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			attrs := make([]string, 0, len(tok.Attr))
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue // namespaces are resolved in names
+				}
+				attrs = append(attrs, fmt.Sprintf("%s=%q", xmlName(a.Name), a.Value))
+			}
+			slices.Sort(attrs)
+
+			line := indent + "<" + xmlName(tok.Name)
+			if len(attrs) > 0 {
+				line += " " + strings.Join(attrs, " ")
+			}
+			lines = append(lines, line+">\n")
+			depth++
+		case xml.EndElement:
+			depth--
+			lines = append(lines, strings.Repeat("  ", depth)+"</"+xmlName(tok.Name)+">\n")
+		case xml.CharData:
+			text := strings.TrimSpace(string(tok))
+			if text != "" {
+				lines = append(lines, indent+text+"\n")
+			}
+		}
+	}
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return "{" + n.Space + "}" + n.Local
+	}
+
+	return n.Local
+}
+
+// CSV compares CSV records by columns named in the header row, skipping ignored columns.
+// Order of columns doesn't matter.
+func CSV(ignoreColumns ...string) Comparer {
+	return ComparerFunc(func(want, got []byte) (string, error) {
+		w, err := readCSV(want)
+		if err != nil {
+			return "", fmt.Errorf("parse want: %w", err)
+		}
+		g, err := readCSV(got)
+		if err != nil {
+			return "", fmt.Errorf("parse got: %w", err)
+		}
+
+		return joinDiffs(diffCSV(w, g, ignoreColumns)), nil
+	})
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	return r.ReadAll()
+}
+
+func diffCSV(want, got [][]string, ignore []string) []string {
+	var wantHeader, gotHeader []string
+	if len(want) > 0 {
+		wantHeader = want[0]
+	}
+	if len(got) > 0 {
+		gotHeader = got[0]
+	}
+
+	var diffs []string
+	var columns []string
+	for _, c := range wantHeader {
+		if slices.Contains(ignore, c) {
+			continue
+		}
+		if !slices.Contains(gotHeader, c) {
+			diffs = append(diffs, fmt.Sprintf("column %q: missing", c))
+			continue
+		}
+		columns = append(columns, c)
+	}
+	for _, c := range gotHeader {
+		if !slices.Contains(ignore, c) && !slices.Contains(wantHeader, c) {
+			diffs = append(diffs, fmt.Sprintf("column %q: unexpected", c))
+		}
+	}
+
+	field := func(header, record []string, column string) string {
+		i := slices.Index(header, column)
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	for i := 1; i < max(len(want), len(got)); i++ {
+		switch {
+		case i >= len(got):
+			diffs = append(diffs, fmt.Sprintf("row %d: missing %q", i+1, want[i]))
+		case i >= len(want):
+			diffs = append(diffs, fmt.Sprintf("row %d: unexpected %q", i+1, got[i]))
+		default:
+			for _, c := range columns {
+				w, g := field(wantHeader, want[i], c), field(gotHeader, got[i], c)
+				if w != g {
+					diffs = append(diffs, fmt.Sprintf("row %d, column %q: want %q, got %q", i+1, c, w, g))
+				}
+			}
+		}
+	}
+
+	return diffs
+}
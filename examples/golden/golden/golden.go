@@ -1,51 +1,336 @@
-// Package golden contains test helpers for reading data from ./testdata/ subdirectory.
+// Package golden contains test helpers for reading data from ./testdata/ subdirectory
+// and comparing test output with golden files.
+//
+// Run tests with -update flag or GOLDEN_UPDATE=1 to rewrite golden files with the actual output:
+//
+//	go test ./... -update
+//	GOLDEN_UPDATE=1 go test ./...
+//
+// Package-level functions read fixtures from ./testdata/. Use New with options
+// to keep fixtures of each test in its own directory or to read them from fs.FS.
 package golden
 
 import (
 	"bytes"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
 
+var update = flag.Bool("update", false, "update golden files")
+
+// updating reports whether golden files should be rewritten instead of compared.
+func updating() bool {
+	if *update {
+		return true
+	}
+
+	v, _ := strconv.ParseBool(os.Getenv("GOLDEN_UPDATE"))
+	return v
+}
+
+// Fixtures reads fixtures and golden files of a test.
+type Fixtures struct {
+	t       testing.TB
+	fsys    fs.FS
+	dir     string // slash-separated, relative to the package directory
+	perTest bool
+}
+
+// Option configures Fixtures.
+type Option func(*Fixtures)
+
+// PerTest scopes fixtures to testdata/<TestName>/<subtest>/.
+// Names of tests are sanitized to lower case letters, digits and underscores.
+func PerTest() Option {
+	return func(f *Fixtures) {
+		f.perTest = true
+	}
+}
+
+// FS reads fixtures from fsys instead of the package directory, for example from embed.FS:
+//
+//	//go:embed testdata
+//	var testdata embed.FS
+//
+// Paths in fsys must be relative to the package directory, as they are for embed.FS,
+// so in update mode golden files are written to the same paths on disk.
+func FS(fsys fs.FS) Option {
+	return func(f *Fixtures) {
+		f.fsys = fsys
+	}
+}
+
+// Dir sets directory of fixtures, relative to the package directory. It is "testdata" by default.
+func Dir(dir string) Option {
+	return func(f *Fixtures) {
+		f.dir = filepath.ToSlash(filepath.Clean(dir))
+	}
+}
+
+// New returns fixtures of the test.
+func New(t testing.TB, opts ...Option) *Fixtures {
+	f := &Fixtures{
+		t:    t,
+		fsys: os.DirFS("."),
+		dir:  "testdata",
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	trackRoot(f.dir)
+	trackSkip(t)
+	if f.perTest {
+		f.dir = path.Join(f.dir, testDir(t.Name()))
+	}
+
+	return f
+}
+
+// testDir returns directory of the test, one directory for a test and each of its subtests.
+// Names are lower cased, other characters than a-z, 0-9 and _ are replaced with _ and each part is cut to 255 bytes.
+func testDir(testName string) string {
+	mapper := func(r rune) rune {
+		// allow only [a-z0-9_]
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+
+		return '_'
+	}
+
+	parts := strings.Split(testName, "/")
+	for i, p := range parts {
+		p = strings.ToLower(p)
+		if len(p) > 255 { // max file name length on most file systems
+			p = p[:255]
+		}
+		parts[i] = strings.Map(mapper, p)
+	}
+
+	return path.Join(parts...)
+}
+
+// name returns slash-separated path of the fixture in fsys. Name may use OS-specific separators.
+func (f *Fixtures) name(name string) string {
+	return path.Join(f.dir, filepath.ToSlash(name))
+}
+
+// Path returns OS-specific path of the fixture on disk, relative to the package directory.
+func (f *Fixtures) Path(name string) string {
+	return filepath.FromSlash(f.name(name))
+}
+
+// snippet:start read
 // Open file and close on test cleanup.
-func Open(t *testing.T, name string) io.ReadSeeker {
-	t.Helper()
+func (f *Fixtures) Open(name string) io.ReadSeeker {
+	f.t.Helper()
 
-	f, err := os.Open(path.Join("testdata", name))
+	track(f.name(name))
+	file, err := f.fsys.Open(f.name(name))
 	if err != nil {
-		t.Fatalf("open file: %s", err)
+		f.t.Fatalf("open file: %s", err)
 	}
 
-	t.Cleanup(func() { f.Close() })
+	f.t.Cleanup(func() { file.Close() })
 
-	return f
+	if rs, ok := file.(io.ReadSeeker); ok {
+		return rs
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		f.t.Fatalf("read file: %s", err)
+	}
+
+	return bytes.NewReader(data)
 }
 
 // ReadString reads file into string.
-func ReadString(t *testing.T, name string) string {
-	t.Helper()
+func (f *Fixtures) ReadString(name string) string {
+	f.t.Helper()
 
 	var buf strings.Builder
-	_, err := io.Copy(&buf, Open(t, name))
+	_, err := io.Copy(&buf, f.Open(name))
 	if err != nil {
-		t.Fatalf("copy file: %s", err)
+		f.t.Fatalf("copy file: %s", err)
 	}
 
 	return buf.String()
 }
 
 // ReadBytes reads file into []byte.
-func ReadBytes(t *testing.T, name string) []byte {
-	t.Helper()
+func (f *Fixtures) ReadBytes(name string) []byte {
+	f.t.Helper()
 
 	var buf bytes.Buffer
-	_, err := io.Copy(&buf, Open(t, name))
+	_, err := io.Copy(&buf, f.Open(name))
 	if err != nil {
-		t.Fatalf("copy file: %s", err)
+		f.t.Fatalf("copy file: %s", err)
 	}
 
 	return buf.Bytes()
 }
+
+// snippet:end read
+
+// Assert compares got with the golden file byte by byte.
+// In update mode, it writes got into the golden file instead.
+func (f *Fixtures) Assert(name string, got []byte) {
+	f.t.Helper()
+
+	f.removeArtifact(f.actualPath(name))
+
+	if updating() {
+		want, err := f.readFile(name)
+		if err == nil && bytes.Equal(want, got) {
+			return
+		}
+
+		f.write(name, got)
+		return
+	}
+
+	want := f.readGolden(name)
+	if !bytes.Equal(want, got) {
+		f.mismatch(name, diffBytes(want, got), got)
+	}
+}
+
+// mismatch fails the test with the diff of the actual output from the golden file.
+// Long diffs are truncated and the actual output is written next to the golden file with .actual extension,
+// it is removed when the assertion passes or the golden file is updated.
+func (f *Fixtures) mismatch(name, diff string, actual []byte) {
+	f.t.Helper()
+
+	diff, rest := truncateDiff(diff)
+	if rest > 0 {
+		p := f.actualPath(name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			f.t.Fatalf("create directory: %s", err)
+		}
+		if err := os.WriteFile(p, actual, 0o644); err != nil {
+			f.t.Fatalf("write actual output: %s", err)
+		}
+		diff += fmt.Sprintf("... %d more lines, see the actual output in %s\n", rest, p)
+	}
+
+	f.t.Errorf("%s mismatch, run with -update to update golden files:\n%s", f.Path(name), diff)
+}
+
+// actualPath returns path of the actual output written by mismatch.
+func (f *Fixtures) actualPath(name string) string {
+	return f.Path(name) + ".actual"
+}
+
+// removeArtifact removes a failure artifact left by a previous run, so artifacts are only there for failing assertions.
+func (f *Fixtures) removeArtifact(p string) {
+	f.t.Helper()
+
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		f.t.Fatalf("remove failure artifact: %s", err)
+	}
+}
+
+// Exists reports whether the fixture exists, for optional inputs and outputs.
+func (f *Fixtures) Exists(name string) bool {
+	track(f.name(name))
+	_, err := fs.Stat(f.fsys, f.name(name))
+	return err == nil
+}
+
+// readFile reads the golden file, it returns an error if the file doesn't exist yet.
+func (f *Fixtures) readFile(name string) ([]byte, error) {
+	track(f.name(name))
+	return fs.ReadFile(f.fsys, f.name(name))
+}
+
+// readDir reads the fixtures directory.
+func (f *Fixtures) readDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.fsys, f.name(name))
+}
+
+// readGolden reads the golden file, failing the test with a hint if it doesn't exist.
+func (f *Fixtures) readGolden(name string) []byte {
+	f.t.Helper()
+
+	data, err := f.readFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.t.Fatalf("golden file %s doesn't exist, run with -update to create it", f.Path(name))
+	}
+	if err != nil {
+		f.t.Fatalf("read golden file: %s", err)
+	}
+
+	return data
+}
+
+// write writes data into the golden file atomically, so an interrupted run doesn't leave a truncated file.
+func (f *Fixtures) write(name string, data []byte) {
+	f.t.Helper()
+
+	track(f.name(name))
+	p := f.Path(name)
+	dir, base := filepath.Split(p)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		f.t.Fatalf("create directory: %s", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, base+".*.tmp")
+	if err != nil {
+		f.t.Fatalf("create temporary file: %s", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after rename
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		f.t.Fatalf("change file mode: %s", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		f.t.Fatalf("write file: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		f.t.Fatalf("close file: %s", err)
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		f.t.Fatalf("rename file: %s", err)
+	}
+
+	f.t.Logf("updated golden file %s", p)
+}
+
+// Open file from ./testdata/ and close on test cleanup.
+func Open(t testing.TB, name string) io.ReadSeeker {
+	t.Helper()
+	return New(t).Open(name)
+}
+
+// ReadString reads file from ./testdata/ into string.
+func ReadString(t testing.TB, name string) string {
+	t.Helper()
+	return New(t).ReadString(name)
+}
+
+// ReadBytes reads file from ./testdata/ into []byte.
+func ReadBytes(t testing.TB, name string) []byte {
+	t.Helper()
+	return New(t).ReadBytes(name)
+}
+
+// Assert compares got with the golden file in ./testdata/ byte by byte.
+// In update mode, it writes got into the golden file instead.
+func Assert(t testing.TB, name string, got []byte) {
+	t.Helper()
+	New(t).Assert(name, got)
+}
@@ -0,0 +1,891 @@
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssert(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setUpdate(t, true)
+
+	Assert(t, "nested/dir/out.txt", []byte("hello\n"))
+
+	if got := ReadString(t, "nested/dir/out.txt"); got != "hello\n" {
+		t.Fatalf("expected written file, got %q", got)
+	}
+
+	setUpdate(t, false)
+	Assert(t, "nested/dir/out.txt", []byte("hello\n"))
+}
+
+func TestAssertJSON(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setUpdate(t, true)
+
+	AssertJSON(t, "response.json", []byte(`{"message":"Hello!","count":1}`))
+	want := "{\n    \"message\": \"Hello!\",\n    \"count\": 1\n}\n"
+	if got := ReadString(t, "response.json"); got != want {
+		t.Fatalf("expected indented JSON %q, got %q", want, got)
+	}
+
+	// semantically equal JSON doesn't rewrite the file
+	stat, err := os.Stat(filepath.Join("testdata", "response.json"))
+	if err != nil {
+		t.Fatalf("stat file: %s", err)
+	}
+	AssertJSON(t, "response.json", []byte(`{"count":1,"message":"Hello!"}`))
+	if got := ReadString(t, "response.json"); got != want {
+		t.Fatalf("expected unchanged file, got %q", got)
+	}
+	if stat2, _ := os.Stat(filepath.Join("testdata", "response.json")); !stat2.ModTime().Equal(stat.ModTime()) {
+		t.Errorf("expected file not to be rewritten")
+	}
+
+	setUpdate(t, false)
+	AssertJSON(t, "response.json", []byte(`{"count": 1, "message": "Hello!"}`))
+}
+
+func TestUpdateEnv(t *testing.T) {
+	setUpdate(t, false)
+
+	t.Setenv("GOLDEN_UPDATE", "1")
+	if !updating() {
+		t.Error("expected update mode with GOLDEN_UPDATE=1")
+	}
+
+	t.Setenv("GOLDEN_UPDATE", "")
+	if updating() {
+		t.Error("expected no update mode without GOLDEN_UPDATE")
+	}
+}
+
+func setUpdate(t *testing.T, v bool) {
+	t.Helper()
+
+	prev := *update
+	*update = v
+	t.Cleanup(func() { *update = prev })
+}
+
+// recordingTB records failures of the helpers instead of failing the test. Other methods go to the real test.
+type recordingTB struct {
+	testing.TB
+
+	mu     sync.Mutex
+	errors []string
+	fatal  bool
+}
+
+// record runs fn with recordingTB in a goroutine, as Fatalf stops it with runtime.Goexit.
+func record(t *testing.T, fn func(tb testing.TB)) *recordingTB {
+	t.Helper()
+
+	r := &recordingTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(r)
+	}()
+	<-done
+
+	return r
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+
+	r.mu.Lock()
+	r.fatal = true
+	r.mu.Unlock()
+
+	runtime.Goexit()
+}
+
+// Failure returns the only recorded failure.
+func (r *recordingTB) Failure(t *testing.T) string {
+	t.Helper()
+
+	if len(r.errors) != 1 {
+		t.Fatalf("expected one failure, got %q", r.errors)
+	}
+
+	return r.errors[0]
+}
+
+func TestAssertFailures(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setUpdate(t, false)
+
+	r := record(t, func(tb testing.TB) { Assert(tb, "missing.txt", []byte("hello\n")) })
+	if got := r.Failure(t); !r.fatal || !strings.Contains(got, "golden file testdata/missing.txt doesn't exist, run with -update to create it") {
+		t.Errorf("expected fatal missing golden file failure, got %q", got)
+	}
+
+	setUpdate(t, true)
+	Assert(t, "short.txt", []byte("a\nb\n"))
+	var long strings.Builder
+	for i := range maxDiffLines {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+	Assert(t, "long.txt", []byte(long.String()))
+	setUpdate(t, false)
+
+	r = record(t, func(tb testing.TB) { Assert(tb, "short.txt", []byte("a\nc\n")) })
+	want := "testdata/short.txt mismatch, run with -update to update golden files:\n" +
+		"--- want\n+++ got\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"
+	if got := r.Failure(t); r.fatal || got != want {
+		t.Errorf("expected failure:\n%s\ngot:\n%s", want, got)
+	}
+	if _, err := os.Stat(filepath.Join("testdata", "short.txt.actual")); err == nil {
+		t.Error("expected no actual output for a short diff")
+	}
+
+	actual := strings.ReplaceAll(long.String(), "line", "LINE")
+	r = record(t, func(tb testing.TB) { Assert(tb, "long.txt", []byte(actual)) })
+	actualPath := filepath.Join("testdata", "long.txt.actual")
+	if got := r.Failure(t); !strings.Contains(got, "more lines, see the actual output in "+actualPath) {
+		t.Errorf("expected failure to point to the actual output, got:\n%s", got)
+	}
+	if data, err := os.ReadFile(actualPath); err != nil || string(data) != actual {
+		t.Errorf("expected actual output in %s, got %q, %v", actualPath, data, err)
+	}
+	Assert(t, "long.txt", []byte(long.String()))
+	if _, err := os.Stat(actualPath); err == nil {
+		t.Errorf("expected %s to be removed when assertion passes", actualPath)
+	}
+
+	r = record(t, func(tb testing.TB) { New(tb).Cases("cases", func(t *testing.T, c Case) {}) })
+	if got := r.Failure(t); !r.fatal || !strings.Contains(got, "fixtures must be created with *testing.T") {
+		t.Errorf("expected cases to require *testing.T, got %q", got)
+	}
+}
+
+func TestJSONDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		opts []JSONOption
+		diff []string
+	}{
+		{
+			name: "equal with different order of keys",
+			want: `{"a": 1, "b": [true, null]}`,
+			got:  `{"b": [true, null], "a": 1}`,
+		},
+		{
+			name: "changed value",
+			want: `{"items": [{"id": 1, "name": "a"}]}`,
+			got:  `{"items": [{"id": 1, "name": "b"}]}`,
+			diff: []string{`$.items[0].name: want "a", got "b"`},
+		},
+		{
+			name: "missing and unexpected",
+			want: `{"a": 1, "list": [1, 2]}`,
+			got:  `{"b": 2, "list": [1], "my key": 3}`,
+			diff: []string{
+				`$.a: missing, want 1`,
+				`$.list[1]: missing, want 2`,
+				`$.b: unexpected 2`,
+				`$['my key']: unexpected 3`,
+			},
+		},
+		{
+			name: "ignore by JSONPath",
+			want: `{"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]}`,
+			got:  `{"items": [{"id": 5, "name": "a"}, {"name": "b"}]}`,
+			opts: []JSONOption{IgnorePaths("$.items[*].id")},
+		},
+		{
+			name: "ignore by descendant",
+			want: `{"created": "x", "nested": {"created": "y"}}`,
+			got:  `{"created": "z", "nested": {"created": "w"}}`,
+			opts: []JSONOption{IgnorePaths("$..created")},
+		},
+		{
+			name: "ignore by JSON Pointer",
+			want: `{"items": [{"id": 1}, {"id": 2}]}`,
+			got:  `{"items": [{"id": 3}, {"id": 4}]}`,
+			opts: []JSONOption{IgnorePaths("/items/0/id")},
+			diff: []string{`$.items[1].id: want 2, got 4`},
+		},
+		{
+			name: "redact",
+			want: `{"id": "<uuid>", "created_at": "<rfc3339>", "token": "<token>"}`,
+			got:  `{"id": "0b6c3b4e-9a43-4bd2-a3f3-2a7a3f0d1c55", "created_at": "2025-06-04T18:27:15+02:00", "token": "secret"}`,
+			opts: []JSONOption{RedactUUIDs(), RedactRFC3339(), RedactPaths("<token>", "$.token")},
+		},
+		{
+			name: "unordered arrays",
+			want: `{"tags": ["a", "b", "c"], "ordered": [1, 2]}`,
+			got:  `{"tags": ["c", "a", "d"], "ordered": [2, 1]}`,
+			opts: []JSONOption{UnorderedArrays("$.tags")},
+			diff: []string{
+				`$.tags[1]: missing, want "b"`,
+				`$.tags[2]: unexpected "d"`,
+				`$.ordered[0]: want 1, got 2`,
+				`$.ordered[1]: want 2, got 1`,
+			},
+		},
+		{
+			name: "number tolerance",
+			want: `{"a": 1.0, "b": 0.3, "c": 1}`,
+			got:  `{"a": 1, "b": 0.30001, "c": 2}`,
+			opts: []JSONOption{NumberTolerance(0.001)},
+			diff: []string{`$.c: want 1, got 2`},
+		},
+		{
+			name: "different types",
+			want: `{"a": "1"}`,
+			got:  `{"a": 1}`,
+			diff: []string{`$.a: want "1", got 1`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := newJSONOptions(tt.opts)
+			if err != nil {
+				t.Fatalf("new options: %s", err)
+			}
+			want, err := parseJSON([]byte(tt.want))
+			if err != nil {
+				t.Fatalf("parse want: %s", err)
+			}
+			got, err := parseJSON([]byte(tt.got))
+			if err != nil {
+				t.Fatalf("parse got: %s", err)
+			}
+
+			diff := o.diff(nil, o.redact(nil, want), o.redact(nil, got))
+			if !slices.Equal(diff, tt.diff) {
+				t.Errorf("expected diff:\n%s\ngot:\n%s", strings.Join(tt.diff, "\n"), strings.Join(diff, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseJSONPathInvalid(t *testing.T) {
+	for _, p := range []string{"items", "$.", "$[x]", "$['a'", "$items"} {
+		if _, err := parseJSONPath(p); err == nil {
+			t.Errorf("expected error for %q", p)
+		}
+	}
+}
+
+func TestAssertJSONUpdateRedacts(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setUpdate(t, true)
+
+	AssertJSON(t, "response.json", []byte(`{"id":"0b6c3b4e-9a43-4bd2-a3f3-2a7a3f0d1c55","message":"<b>Hello</b>"}`), RedactUUIDs())
+
+	want := "{\n    \"id\": \"<uuid>\",\n    \"message\": \"<b>Hello</b>\"\n}\n"
+	if got := ReadString(t, "response.json"); got != want {
+		t.Fatalf("expected redacted JSON %q, got %q", want, got)
+	}
+}
+
+func TestTestDir(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "TestGreet", want: "testgreet"},
+		{name: "TestGreet/empty name", want: "testgreet/empty_name"},
+		{name: "TestGreet/Ünicode-name/#01", want: "testgreet/_nicode_name/_01"},
+	}
+
+	for _, tt := range tests {
+		if got := testDir(tt.name); got != tt.want {
+			t.Errorf("testDir(%q): expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPerTest(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setUpdate(t, true)
+
+	t.Run("Sub Case", func(t *testing.T) {
+		f := New(t, PerTest())
+		f.Assert("out.txt", []byte("hello"))
+
+		want := filepath.Join("testdata", "testpertest", "sub_case", "out.txt")
+		if got := f.Path("out.txt"); got != want {
+			t.Errorf("expected path %q, got %q", want, got)
+		}
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("expected written file: %s", err)
+		}
+	})
+}
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fixtures/TestFS/request.json": {Data: []byte(`{"name": "John Doe"}`)},
+	}
+
+	f := New(t, FS(fsys), Dir("fixtures"))
+	if got := f.ReadString(filepath.Join("TestFS", "request.json")); got != `{"name": "John Doe"}` {
+		t.Errorf("unexpected content %q", got)
+	}
+
+	f.AssertJSON("TestFS/request.json", []byte(`{"name":"John Doe"}`))
+}
+
+func TestCases(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setUpdate(t, true)
+
+	for name, data := range map[string]string{
+		"testdata/cases/first/input.txt":  "first",
+		"testdata/cases/second/input.txt": "second",
+		"testdata/cases/README.md":        "not a case",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatalf("create directory: %s", err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatalf("write file: %s", err)
+		}
+	}
+
+	t.Run("run", func(t *testing.T) {
+		Cases(t, "cases", func(t *testing.T, c Case) {
+			if !c.Exists("input.txt") || c.Exists("missing.txt") {
+				t.Errorf("unexpected fixtures of case %s", c.Name)
+			}
+			c.Assert("output.txt", []byte(strings.ToUpper(c.ReadString("input.txt"))))
+		})
+	})
+
+	for name, want := range map[string]string{
+		"testdata/cases/first/output.txt":  "FIRST",
+		"testdata/cases/second/output.txt": "SECOND",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read output: %s", err)
+		}
+		if string(got) != want {
+			t.Errorf("expected %s to be %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestAssertHTTP(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "0b6c3b4e-9a43-4bd2-a3f3-2a7a3f0d1c55")
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":"0b6c3b4e-9a43-4bd2-a3f3-2a7a3f0d1c55","request":%s}`, body)
+	})
+	request := "PUT /items\nContent-Type: application/json\n\n{\"name\": \"a\"}\n\n###\n"
+	if err := os.MkdirAll("testdata", 0o755); err != nil {
+		t.Fatalf("create directory: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join("testdata", "put.http"), []byte(request), 0o644); err != nil {
+		t.Fatalf("write transcript: %s", err)
+	}
+
+	opts := []HTTPOption{IgnoreHeaders("X-Request-Id"), JSONBody(RedactUUIDs())}
+
+	setUpdate(t, true)
+	AssertHTTP(t, "put.http", h, opts...)
+
+	want := request + "\nHTTP/1.1 201 Created\nContent-Type: application/json\nX-Method: PUT\n\n" +
+		"{\n    \"id\": \"<uuid>\",\n    \"request\": {\n        \"name\": \"a\"\n    }\n}\n"
+	if got := ReadString(t, "put.http"); got != want {
+		t.Fatalf("expected transcript:\n%s\ngot:\n%s", want, got)
+	}
+
+	setUpdate(t, false)
+	AssertHTTP(t, "put.http", h, opts...)
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	AssertHTTPServer(t, "put.http", srv, opts...)
+
+	// transcripts edited on Windows have CRLF line endings
+	crlf := strings.ReplaceAll(want, "\n", "\r\n")
+	if err := os.WriteFile(filepath.Join("testdata", "put_crlf.http"), []byte(crlf), 0o644); err != nil {
+		t.Fatalf("write transcript: %s", err)
+	}
+	AssertHTTP(t, "put_crlf.http", h, opts...)
+}
+
+func TestHTTPResponseDiff(t *testing.T) {
+	want, err := parseResponse("HTTP/1.1 200 OK\nContent-Type: application/json\nX-Version: 1\nCache-Control: no-store\n\n{\"message\": \"hello\"}\n")
+	if err != nil {
+		t.Fatalf("parse response: %s", err)
+	}
+	got := httpResponse{
+		status: "404 Not Found",
+		header: http.Header{"Content-Type": {"application/json"}, "X-Version": {"2"}, "Vary": {"Accept"}},
+		body:   []byte(`{"message":"hi"}`),
+	}
+
+	o := &httpOptions{}
+	AllowHeaders("content-type", "x-version", "vary")(o)
+	jsonOpts, err := newJSONOptions(nil)
+	if err != nil {
+		t.Fatalf("new options: %s", err)
+	}
+
+	wantDiff := []string{
+		`status: want "200 OK", got "404 Not Found"`,
+		`header Vary: unexpected "Accept"`,
+		`header X-Version: want "1", got "2"`,
+		`body $.message: want "hello", got "hi"`,
+	}
+	if diff := got.diff(want, o, jsonOpts); !slices.Equal(diff, wantDiff) {
+		t.Errorf("expected diff:\n%s\ngot:\n%s", strings.Join(wantDiff, "\n"), strings.Join(diff, "\n"))
+	}
+}
+
+func TestDiffBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		diff string
+	}{
+		{
+			name: "equal",
+			want: "a\nb\n",
+			got:  "a\nb\n",
+			diff: "",
+		},
+		{
+			name: "changed line with context",
+			want: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			got:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			diff: "--- want\n+++ got\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			want: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			got:  "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			diff: "--- want\n+++ got\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			name: "added lines",
+			want: "a\n",
+			got:  "a\nb\nc\n",
+			diff: "--- want\n+++ got\n@@ -1 +1,3 @@\n a\n+b\n+c\n",
+		},
+		{
+			name: "whitespace",
+			want: "key: value\n\tindented\n",
+			got:  "key: value  \n    indented\n",
+			diff: "--- want\n+++ got\n@@ -1,2 +1,2 @@\n-key: value\n-→indented\n+key: value··\n+    indented\n",
+		},
+		{
+			name: "line endings",
+			want: "a\nb\n",
+			got:  "a\r\nb",
+			diff: "--- want\n+++ got\n@@ -1,2 +1,2 @@\n-a\n-b\n+a␍\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "binary",
+			want: "\x00\x01hello",
+			got:  "\x00\x02hello",
+			diff: "--- want\n+++ got\n@@ -1 +1 @@\n" +
+				"-00000000  00 01 68 65 6c 6c 6f                              |..hello|\n" +
+				"+00000000  00 02 68 65 6c 6c 6f                              |..hello|\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffBytes([]byte(tt.want), []byte(tt.got)); got != tt.diff {
+				t.Errorf("expected diff:\n%s\ngot:\n%s", tt.diff, got)
+			}
+		})
+	}
+}
+
+func TestTruncateDiff(t *testing.T) {
+	diff := strings.Repeat("+line\n", maxDiffLines+5)
+
+	got, rest := truncateDiff(diff)
+	if rest != 5 {
+		t.Errorf("expected 5 cut lines, got %d", rest)
+	}
+	if strings.Count(got, "\n") != maxDiffLines {
+		t.Errorf("expected %d lines, got %d", maxDiffLines, strings.Count(got, "\n"))
+	}
+
+	if got, rest := truncateDiff("+line\n"); got != "+line\n" || rest != 0 {
+		t.Errorf("expected short diff unchanged, got %q, %d", got, rest)
+	}
+}
+
+func TestComparers(t *testing.T) {
+	tests := []struct {
+		name     string
+		comparer Comparer
+		want     string
+		got      string
+		diff     string
+	}{
+		{
+			name:     "YAML equal",
+			comparer: YAML(),
+			want:     "name: blog\ntags: [go, testing]\n",
+			got:      "tags:\n  - go\n  - testing\nname: \"blog\"\n",
+		},
+		{
+			name:     "YAML changed",
+			comparer: YAML(),
+			want:     "sites:\n  - name: blog\n    port: 8080\n",
+			got:      "sites:\n  - name: blog\n    port: 8081\n",
+			diff:     "$.sites[0].port: want 8080, got 8081\n",
+		},
+		{
+			name:     "TOML with options",
+			comparer: TOML(IgnorePaths("$.build.date")),
+			want:     "[build]\ndate = 2025-06-04T18:27:15Z\ndrafts = false\n",
+			got:      "[build]\ndrafts = true\ndate = 2025-06-05T10:00:00Z\n",
+			diff:     "$.build.drafts: want false, got true\n",
+		},
+		{
+			name:     "XML equal",
+			comparer: XML(),
+			want:     `<rss version="2.0"><channel><title>Blog</title><link href="/" rel="self"/></channel></rss>`,
+			got:      "<?xml version=\"1.0\"?>\n<rss version=\"2.0\">\n  <!-- generated -->\n  <channel>\n    <title> Blog </title>\n    <link rel=\"self\" href=\"/\"></link>\n  </channel>\n</rss>\n",
+		},
+		{
+			name:     "XML changed",
+			comparer: XML(),
+			want:     `<rss><channel><title>Blog</title></channel></rss>`,
+			got:      `<rss><channel><title>Posts</title></channel></rss>`,
+			diff:     "--- want\n+++ got\n@@ -1,7 +1,7 @@\n <rss>\n   <channel>\n     <title>\n-      Blog\n+      Posts\n     </title>\n   </channel>\n </rss>\n",
+		},
+		{
+			name:     "HTML equal",
+			comparer: HTML(),
+			want:     "<!DOCTYPE html><html><body><p class=\"a b\" id=\"x\">Hello,\n  world<br></p></body></html>",
+			got:      "<HTML>\n<body>\n<p id=x class=\"a b\">Hello, world<br/></p>\n</body>\n</HTML>",
+		},
+		{
+			name:     "HTML omitted end tags",
+			comparer: HTML(),
+			want:     "<p>a</p><p>b</p><ul><li>one</li><li>two</li></ul>",
+			got:      "<p>a<p>b<ul><li>one<li>two</ul>",
+		},
+		{
+			name:     "HTML script",
+			comparer: HTML(),
+			want:     "<script>if (a < b) { run() }</script><p>after</p>",
+			got:      "<script>if (a < b) { stop() }</script><p>after</p>",
+			diff: "--- want\n+++ got\n@@ -1,7 +1,7 @@\n <html>\n   <head>\n     <script>\n-      if (a < b) { run() }\n+      if (a < b) { stop() }\n" +
+				"     </script>\n   </head>\n   <body>\n",
+		},
+		{
+			name:     "HTML pre",
+			comparer: HTML(),
+			want:     "<pre>a\n  b</pre>",
+			got:      "<pre>a\nb</pre>",
+			diff: "--- want\n+++ got\n@@ -3,7 +3,7 @@\n   </head>\n   <body>\n     <pre>\n-      \"a\\n  b\"\n+      \"a\\nb\"\n" +
+				"     </pre>\n   </body>\n </html>\n",
+		},
+		{
+			name:     "CSV with ignored columns",
+			comparer: CSV("id"),
+			want:     "id,name,count\n1,go,3\n2,http3,5\n",
+			got:      "name,id,count\ngo,7,3\nhttp3,8,4\ntesting,9,1\n",
+			diff:     "row 3, column \"count\": want \"5\", got \"4\"\nrow 4: unexpected [\"testing\" \"9\" \"1\"]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := tt.comparer.Compare([]byte(tt.want), []byte(tt.got))
+			if err != nil {
+				t.Fatalf("compare: %s", err)
+			}
+			if diff != tt.diff {
+				t.Errorf("expected diff:\n%s\ngot:\n%s", tt.diff, diff)
+			}
+		})
+	}
+}
+
+func TestAssertFormat(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	Register(".upper", ComparerFunc(func(want, got []byte) (string, error) {
+		if !strings.EqualFold(string(want), string(got)) {
+			return "differs\n", nil
+		}
+		return "", nil
+	}))
+	t.Cleanup(func() {
+		comparersMu.Lock()
+		delete(comparers, ".upper")
+		comparersMu.Unlock()
+	})
+
+	setUpdate(t, true)
+	AssertFormat(t, "out.upper", []byte("HELLO"))
+	AssertFormat(t, "config.yaml", []byte("a: 1\nb: 2\n"))
+
+	setUpdate(t, false)
+	AssertFormat(t, "out.upper", []byte("hello"))
+	AssertFormat(t, "config.yaml", []byte("b: 2\na: 1\n"))
+}
+
+func TestCompareImages(t *testing.T) {
+	want := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	got := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := range want.Pix {
+		want.Pix[i], got.Pix[i] = 100, 100
+	}
+	got.SetNRGBA(0, 0, color.NRGBA{110, 100, 100, 100}) // within tolerance
+	got.SetNRGBA(1, 1, color.NRGBA{200, 100, 100, 100})
+
+	res := compareImages(want, got, &imageOptions{tolerance: 10})
+	if res.differing != 1 || res.total != 4 {
+		t.Fatalf("expected 1 of 4 pixels to differ, got %d of %d", res.differing, res.total)
+	}
+	if c := res.diff.NRGBAAt(1, 1); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected differing pixel in red, got %v", c)
+	}
+	if c := res.diff.NRGBAAt(0, 0); c.R != c.G || c.R < 191 {
+		t.Errorf("expected equal pixel in light gray, got %v", c)
+	}
+
+	if res.ok(&imageOptions{maxRatio: 0.2}) {
+		t.Error("expected 25% of differing pixels to exceed 20%")
+	}
+	if !res.ok(&imageOptions{maxRatio: 0.25}) {
+		t.Error("expected 25% of differing pixels to be allowed")
+	}
+
+	if res := compareImages(want, image.NewNRGBA(image.Rect(0, 0, 3, 2)), &imageOptions{}); !res.sizeMismatch {
+		t.Error("expected size mismatch")
+	}
+}
+
+func TestAssertImage(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("encode JPEG: %s", err)
+	}
+
+	setUpdate(t, true)
+	AssertImage(t, "gradient.png", jpg.Bytes())
+
+	if data := ReadBytes(t, "gradient.png"); !bytes.HasPrefix(data, []byte("\x89PNG")) {
+		t.Fatalf("expected golden image to be PNG")
+	}
+
+	setUpdate(t, false)
+	AssertImage(t, "gradient.png", jpg.Bytes())
+
+	var png8 bytes.Buffer
+	if err := png.Encode(&png8, img); err != nil {
+		t.Fatalf("encode PNG: %s", err)
+	}
+	AssertImage(t, "gradient.png", png8.Bytes(), ChannelTolerance(16), MaxDiffRatio(0.01))
+
+	goldenPNG := ReadBytes(t, "gradient.png")
+	decoded, err := png.Decode(bytes.NewReader(goldenPNG))
+	if err != nil {
+		t.Fatalf("decode golden image: %s", err)
+	}
+	changed := toNRGBA(decoded)
+	changed.SetNRGBA(3, 5, color.NRGBA{255, 255, 255, 255})
+	var changedPNG bytes.Buffer
+	if err := png.Encode(&changedPNG, changed); err != nil {
+		t.Fatalf("encode PNG: %s", err)
+	}
+
+	diffPath := filepath.Join("testdata", "gradient.diff.png")
+	r := record(t, func(tb testing.TB) { AssertImage(tb, "gradient.png", changedPNG.Bytes()) })
+	want := "testdata/gradient.png mismatch, run with -update to update golden files: 1 of 256 pixels (0.3906%) differ, allowed 0.0000%, see " + diffPath
+	if got := r.Failure(t); got != want {
+		t.Errorf("expected failure %q, got %q", want, got)
+	}
+
+	data, err := os.ReadFile(diffPath)
+	if err != nil {
+		t.Fatalf("read diff image: %s", err)
+	}
+	diffImg, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode diff image: %s", err)
+	}
+	if got := color.NRGBAModel.Convert(diffImg.At(3, 5)); got != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected differing pixel in red, got %v", got)
+	}
+	if got := color.NRGBAModel.Convert(diffImg.At(0, 0)).(color.NRGBA); got.R != got.G || got.R < 191 {
+		t.Errorf("expected equal pixel in light gray, got %v", got)
+	}
+
+	AssertImage(t, "gradient.png", goldenPNG)
+	if _, err := os.Stat(diffPath); err == nil {
+		t.Errorf("expected %s to be removed when assertion passes", diffPath)
+	}
+}
+
+func TestUnusedFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testdata/request.json":           {},
+		"testdata/stale.json":             {},
+		"testdata/cases/a/request.json":   {},
+		"testdata/cases/b/request.json":   {},
+		"testdata/cases/b/response.json":  {},
+		"testdata/out.txt.actual":         {},
+		"testdata/og.diff.png":            {},
+		"fixtures/testfs/unused.txt":      {},
+		"other/not-a-fixture-root.txt":    {},
+		"testdata/http/greet.http":        {},
+		"testdata/http/wrong_method.http": {},
+	}
+	used := map[string]bool{
+		"testdata/request.json":          true,
+		"testdata/cases/a/request.json":  true,
+		"testdata/cases/b/request.json":  true,
+		"testdata/cases/b/response.json": true,
+		"testdata/http/greet.http":       true,
+	}
+
+	got, err := unusedFiles(fsys, []string{"testdata", "testdata/http", "fixtures", "missing"}, used)
+	if err != nil {
+		t.Fatalf("find unused files: %s", err)
+	}
+
+	want := []string{
+		"fixtures/testfs/unused.txt",
+		"testdata/http/wrong_method.http",
+		"testdata/stale.json",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestCheckUnusedFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	for _, name := range []string{
+		"testdata/request.json",
+		"testdata/stale.json",
+		"testdata/cases/removed/request.json",
+		"testdata/out.txt.actual",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatalf("create directory: %s", err)
+		}
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatalf("write file: %s", err)
+		}
+	}
+	used := map[string]bool{"testdata/request.json": true}
+	exists := func(name string) bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}
+
+	var out strings.Builder
+	if code := checkUnusedFiles(&out, []string{"testdata"}, used, false); code != 1 {
+		t.Errorf("expected exit code 1 for unused files, got %d", code)
+	}
+	want := "golden: fixtures not used by any test, run with -update to remove them:\n" +
+		"\t" + filepath.Join("testdata", "cases", "removed", "request.json") + "\n" +
+		"\t" + filepath.Join("testdata", "stale.json") + "\n"
+	if out.String() != want {
+		t.Errorf("expected report:\n%s\ngot:\n%s", want, out.String())
+	}
+	if !exists("testdata/stale.json") {
+		t.Fatal("expected unused files to be kept without update")
+	}
+
+	out.Reset()
+	if code := checkUnusedFiles(&out, []string{"testdata"}, used, true); code != 0 {
+		t.Errorf("expected exit code 0 after removing, got %d:\n%s", code, out.String())
+	}
+	for _, name := range []string{"testdata/stale.json", "testdata/cases/removed/request.json", "testdata/cases"} {
+		if exists(name) {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+	for _, name := range []string{"testdata/request.json", "testdata/out.txt.actual"} {
+		if !exists(name) {
+			t.Errorf("expected %s to be kept", name)
+		}
+	}
+}
+
+func TestPartialRun(t *testing.T) {
+	if reason := partialRun(); reason != "" {
+		t.Skipf("not a full run: %s", reason)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		if err := flag.Set("test.list", "."); err != nil {
+			t.Fatalf("set flag: %s", err)
+		}
+		t.Cleanup(func() { flag.Set("test.list", "") })
+
+		if got := partialRun(); got != "-list doesn't run tests" {
+			t.Errorf("expected -list reason, got %q", got)
+		}
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		t.Cleanup(func() {
+			accessed.Lock()
+			accessed.skipped = false
+			accessed.Unlock()
+		})
+
+		t.Run("skip", func(t *testing.T) {
+			New(t)
+			t.Skip("fixtures of the test are not used")
+		})
+		if got := partialRun(); got != "some tests were skipped" {
+			t.Errorf("expected skipped tests reason, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,338 @@
+package golden
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// transcriptSeparator separates request and response in .http transcript files.
+const transcriptSeparator = "###"
+
+// HTTPOption configures comparison of AssertHTTP.
+type HTTPOption func(*httpOptions)
+
+type httpOptions struct {
+	allow  []string
+	ignore []string
+	json   []JSONOption
+}
+
+// AllowHeaders compares only the listed response headers, other headers are neither compared nor written in update mode.
+func AllowHeaders(names ...string) HTTPOption {
+	return func(o *httpOptions) {
+		for _, n := range names {
+			o.allow = append(o.allow, http.CanonicalHeaderKey(n))
+		}
+	}
+}
+
+// IgnoreHeaders skips the listed response headers. Date and Content-Length are always skipped.
+func IgnoreHeaders(names ...string) HTTPOption {
+	return func(o *httpOptions) {
+		for _, n := range names {
+			o.ignore = append(o.ignore, http.CanonicalHeaderKey(n))
+		}
+	}
+}
+
+// JSONBody sets options for comparing JSON bodies of responses.
+func JSONBody(opts ...JSONOption) HTTPOption {
+	return func(o *httpOptions) {
+		o.json = append(o.json, opts...)
+	}
+}
+
+func (o *httpOptions) compared(name string) bool {
+	if len(o.allow) > 0 && !slices.Contains(o.allow, name) {
+		return false
+	}
+
+	return !slices.Contains(o.ignore, name)
+}
+
+// AssertHTTP sends the request of the .http transcript in ./testdata/ to h, see Fixtures.AssertHTTP.
+func AssertHTTP(t testing.TB, name string, h http.Handler, opts ...HTTPOption) {
+	t.Helper()
+	New(t).AssertHTTP(name, h, opts...)
+}
+
+// AssertHTTPServer sends the request of the .http transcript in ./testdata/ to srv, see Fixtures.AssertHTTPServer.
+func AssertHTTPServer(t testing.TB, name string, srv *httptest.Server, opts ...HTTPOption) {
+	t.Helper()
+	New(t).AssertHTTPServer(name, srv, opts...)
+}
+
+// AssertHTTP sends the request of the .http transcript file to h and compares the response with the expected one.
+// Transcript has a raw request and a raw response, separated by ### line:
+//
+//	POST /greet HTTP/1.1
+//	Content-Type: application/json
+//
+//	{"name": "John Doe"}
+//
+//	###
+//
+//	HTTP/1.1 200 OK
+//	Content-Type: application/json
+//
+//	{"message": "Hello, John Doe!"}
+//
+// Content-Length is not required, trailing new lines of bodies are not significant, CRLF line endings are read as LF.
+// JSON bodies are compared as JSON values, other bodies as text.
+// In update mode, it rewrites the response of the transcript, keeping the request.
+func (f *Fixtures) AssertHTTP(name string, h http.Handler, opts ...HTTPOption) {
+	f.t.Helper()
+
+	f.assertHTTP(name, func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec.Result(), nil
+	}, opts)
+}
+
+// AssertHTTPServer is like AssertHTTP, but sends the request with the client of srv,
+// so it goes through the real server, its middleware and TLS, if it is started with it.
+func (f *Fixtures) AssertHTTPServer(name string, srv *httptest.Server, opts ...HTTPOption) {
+	f.t.Helper()
+
+	f.assertHTTP(name, func(req *http.Request) (*http.Response, error) {
+		u, err := url.Parse(srv.URL + req.RequestURI)
+		if err != nil {
+			return nil, err
+		}
+
+		req.URL = u
+		req.Host = u.Host
+		req.RequestURI = ""
+
+		return srv.Client().Do(req)
+	}, opts)
+}
+
+// assertHTTP compares response to the transcript request, sent with send, with the transcript response.
+func (f *Fixtures) assertHTTP(name string, send func(*http.Request) (*http.Response, error), opts []HTTPOption) {
+	f.t.Helper()
+
+	f.removeArtifact(f.actualPath(name))
+
+	o := &httpOptions{ignore: []string{"Date", "Content-Length"}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	jsonOpts, err := newJSONOptions(o.json)
+	if err != nil {
+		f.t.Fatalf("invalid JSON option: %s", err)
+	}
+
+	transcript := strings.ReplaceAll(string(f.readGolden(name)), "\r\n", "\n")
+	rawReq, rawResp, ok := strings.Cut(transcript, "\n"+transcriptSeparator+"\n")
+	if !ok {
+		f.t.Fatalf("transcript %s has no %s line between request and response", f.Path(name), transcriptSeparator)
+	}
+
+	req, err := parseRequest(rawReq)
+	if err != nil {
+		f.t.Fatalf("parse request of %s: %s", f.Path(name), err)
+	}
+
+	resp, err := send(req)
+	if err != nil {
+		f.t.Fatalf("send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	gotBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		f.t.Fatalf("read response body: %s", err)
+	}
+	got := httpResponse{status: resp.Status, header: resp.Header, body: gotBody}
+
+	if updating() {
+		formatted, err := got.format(o, jsonOpts)
+		if err != nil {
+			f.t.Fatalf("format response: %s", err)
+		}
+
+		if want, err := parseResponse(rawResp); err == nil && len(got.diff(want, o, jsonOpts)) == 0 {
+			return
+		}
+
+		f.write(name, []byte(rawReq+"\n"+transcriptSeparator+"\n\n"+formatted))
+		return
+	}
+
+	want, err := parseResponse(rawResp)
+	if err != nil {
+		f.t.Fatalf("parse response of %s: %s", f.Path(name), err)
+	}
+
+	if diffs := got.diff(want, o, jsonOpts); len(diffs) > 0 {
+		formatted, err := got.format(o, jsonOpts)
+		if err != nil {
+			formatted = string(gotBody)
+		}
+		f.mismatch(name, strings.Join(diffs, "\n")+"\n", []byte(rawReq+"\n"+transcriptSeparator+"\n\n"+formatted))
+	}
+}
+
+// parseRequest parses raw request, HTTP version of the request line is optional.
+func parseRequest(raw string) (*http.Request, error) {
+	head, body := splitMessage(raw)
+
+	line, rest, _ := strings.Cut(head, "\n")
+	if len(strings.Fields(line)) == 2 {
+		line += " HTTP/1.1"
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(line + "\n" + rest + "\n\n")))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Del("Content-Length")
+
+	return req, nil
+}
+
+// httpResponse is a response of a transcript.
+type httpResponse struct {
+	status string
+	header http.Header
+	body   []byte
+}
+
+func parseResponse(raw string) (httpResponse, error) {
+	head, body := splitMessage(raw)
+
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(head+"\n\n")), nil)
+	if err != nil {
+		return httpResponse{}, err
+	}
+	resp.Body.Close()
+
+	return httpResponse{status: resp.Status, header: resp.Header, body: []byte(body)}, nil
+}
+
+// splitMessage splits message into head and body, trimming blank lines around them.
+func splitMessage(raw string) (string, string) {
+	raw = strings.TrimLeft(raw, "\n")
+
+	head, body, _ := strings.Cut(raw, "\n\n")
+
+	return head, strings.TrimRight(body, "\n")
+}
+
+func (r httpResponse) isJSON() bool {
+	mt, _, err := mime.ParseMediaType(r.header.Get("Content-Type"))
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// diff returns differences of the response from the expected one.
+func (r httpResponse) diff(want httpResponse, o *httpOptions, jsonOpts *jsonOptions) []string {
+	var diffs []string
+	if r.status != want.status {
+		diffs = append(diffs, fmt.Sprintf("status: want %q, got %q", want.status, r.status))
+	}
+
+	names := make(map[string]bool)
+	for k := range want.header {
+		names[k] = true
+	}
+	for k := range r.header {
+		names[k] = true
+	}
+	for _, k := range sortedKeys(names) {
+		if !o.compared(k) {
+			continue
+		}
+
+		w, g := want.header.Values(k), r.header.Values(k)
+		switch {
+		case len(w) == 0:
+			diffs = append(diffs, fmt.Sprintf("header %s: unexpected %q", k, strings.Join(g, ", ")))
+		case len(g) == 0:
+			diffs = append(diffs, fmt.Sprintf("header %s: missing, want %q", k, strings.Join(w, ", ")))
+		case !slices.Equal(w, g):
+			diffs = append(diffs, fmt.Sprintf("header %s: want %q, got %q", k, strings.Join(w, ", "), strings.Join(g, ", ")))
+		}
+	}
+
+	wantBody, gotBody := bytes.TrimRight(want.body, "\r\n"), bytes.TrimRight(r.body, "\r\n")
+	if r.isJSON() {
+		wantValue, wantErr := parseJSON(wantBody)
+		gotValue, gotErr := parseJSON(gotBody)
+		if wantErr == nil && gotErr == nil {
+			for _, d := range jsonOpts.diff(nil, jsonOpts.redact(nil, wantValue), jsonOpts.redact(nil, gotValue)) {
+				diffs = append(diffs, "body "+d)
+			}
+			return diffs
+		}
+	}
+
+	if !bytes.Equal(wantBody, gotBody) {
+		diffs = append(diffs, "body:\n"+strings.TrimSuffix(diffBytes(wantBody, gotBody), "\n"))
+	}
+
+	return diffs
+}
+
+// format formats response for the transcript: status line, compared headers in sorted order and the body.
+func (r httpResponse) format(o *httpOptions, jsonOpts *jsonOptions) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP/1.1 %s\n", r.status)
+
+	names := make(map[string]bool)
+	for k := range r.header {
+		names[k] = true
+	}
+	for _, k := range sortedKeys(names) {
+		if !o.compared(k) {
+			continue
+		}
+		for _, v := range r.header.Values(k) {
+			fmt.Fprintf(&b, "%s: %s\n", k, v)
+		}
+	}
+
+	body := bytes.TrimRight(r.body, "\r\n")
+	if len(body) == 0 {
+		return b.String(), nil
+	}
+
+	b.WriteString("\n")
+	if r.isJSON() {
+		v, err := parseJSON(body)
+		if err != nil {
+			return "", fmt.Errorf("parse JSON body: %w", err)
+		}
+		b.Write(formatJSON(jsonOpts.redact(nil, v)))
+		return b.String(), nil
+	}
+
+	b.Write(body)
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,198 @@
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register decoder
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ImageOption configures comparison of AssertImage.
+type ImageOption func(*imageOptions)
+
+type imageOptions struct {
+	tolerance uint8
+	maxRatio  float64
+}
+
+// ChannelTolerance treats pixels as equal when each of their RGBA channels differs by at most n of 255.
+// It allows for differences of lossy encoding and anti-aliasing.
+func ChannelTolerance(n uint8) ImageOption {
+	return func(o *imageOptions) {
+		o.tolerance = n
+	}
+}
+
+// MaxDiffRatio allows up to ratio, from 0 to 1, of pixels to differ.
+func MaxDiffRatio(ratio float64) ImageOption {
+	return func(o *imageOptions) {
+		o.maxRatio = ratio
+	}
+}
+
+// AssertImage compares image with the golden image in ./testdata/, see Fixtures.AssertImage.
+func AssertImage(t testing.TB, name string, got []byte, opts ...ImageOption) {
+	t.Helper()
+	New(t).AssertImage(name, got, opts...)
+}
+
+// AssertImage decodes PNG, JPEG or GIF image got and compares it with the golden image pixel by pixel.
+// By default all pixels must be equal, use ChannelTolerance and MaxDiffRatio to allow differences.
+// On failure it writes image with differing pixels in red next to the golden file, like og.diff.png for og.png,
+// it is removed when the assertion passes or the golden file is updated.
+// In update mode, it writes got as PNG into the golden file, if it differs from the golden one.
+func (f *Fixtures) AssertImage(name string, got []byte, opts ...ImageOption) {
+	f.t.Helper()
+
+	f.removeArtifact(f.diffImagePath(name))
+
+	o := &imageOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	gotImg, _, err := image.Decode(bytes.NewReader(got))
+	if err != nil {
+		f.t.Fatalf("decode got image: %s", err)
+	}
+	gotNRGBA := toNRGBA(gotImg)
+
+	if updating() {
+		if want, err := f.readFile(name); err == nil {
+			if wantImg, _, err := image.Decode(bytes.NewReader(want)); err == nil {
+				if res := compareImages(toNRGBA(wantImg), gotNRGBA, o); res.ok(o) {
+					return
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, gotNRGBA); err != nil {
+			f.t.Fatalf("encode PNG: %s", err)
+		}
+		f.write(name, buf.Bytes())
+		return
+	}
+
+	wantImg, _, err := image.Decode(bytes.NewReader(f.readGolden(name)))
+	if err != nil {
+		f.t.Fatalf("decode golden image %s: %s", f.Path(name), err)
+	}
+
+	res := compareImages(toNRGBA(wantImg), gotNRGBA, o)
+	if res.ok(o) {
+		return
+	}
+
+	if res.sizeMismatch {
+		f.t.Errorf("%s mismatch, run with -update to update golden files: want size %s, got %s",
+			f.Path(name), wantImg.Bounds().Size(), gotImg.Bounds().Size())
+		return
+	}
+
+	diffPath := f.diffImagePath(name)
+	if err := writeDiffImage(diffPath, res.diff); err != nil {
+		f.t.Fatalf("write diff image: %s", err)
+	}
+
+	f.t.Errorf("%s mismatch, run with -update to update golden files: %d of %d pixels (%.4f%%) differ, allowed %.4f%%, see %s",
+		f.Path(name), res.differing, res.total, 100*res.ratio(), 100*o.maxRatio, diffPath)
+}
+
+// diffImagePath returns path of the image written on mismatch, like og.diff.png for og.png.
+func (f *Fixtures) diffImagePath(name string) string {
+	return strings.TrimSuffix(f.Path(name), filepath.Ext(name)) + ".diff.png"
+}
+
+// toNRGBA converts image to 8-bit non-premultiplied RGBA with bounds starting at (0, 0).
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+
+	return dst
+}
+
+type imageComparison struct {
+	sizeMismatch bool
+	differing    int
+	total        int
+	// diff is the want image faded, with differing pixels in red.
+	diff *image.NRGBA
+}
+
+func (c imageComparison) ratio() float64 {
+	if c.total == 0 {
+		return 0
+	}
+
+	return float64(c.differing) / float64(c.total)
+}
+
+func (c imageComparison) ok(o *imageOptions) bool {
+	return !c.sizeMismatch && c.ratio() <= o.maxRatio
+}
+
+func compareImages(want, got *image.NRGBA, o *imageOptions) imageComparison {
+	if want.Bounds() != got.Bounds() {
+		return imageComparison{sizeMismatch: true}
+	}
+
+	res := imageComparison{
+		total: want.Bounds().Dx() * want.Bounds().Dy(),
+		diff:  image.NewNRGBA(want.Bounds()),
+	}
+	for i := 0; i < len(want.Pix); i += 4 {
+		w, g := want.Pix[i:i+4], got.Pix[i:i+4]
+
+		differs := false
+		for c := range 4 {
+			if absDiff(w[c], g[c]) > o.tolerance {
+				differs = true
+				break
+			}
+		}
+
+		if differs {
+			res.differing++
+			copy(res.diff.Pix[i:i+4], []uint8{255, 0, 0, 255})
+			continue
+		}
+
+		// light gray version of the pixel, so the changes stand out
+		gray := color.GrayModel.Convert(color.NRGBA{w[0], w[1], w[2], 255}).(color.Gray).Y
+		faded := 191 + gray/4
+		copy(res.diff.Pix[i:i+4], []uint8{faded, faded, faded, 255})
+	}
+
+	return res
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+func writeDiffImage(name string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("encode PNG: %w", err)
+	}
+
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,608 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// JSONOption configures comparison of AssertJSON.
+//
+// Paths of options are JSONPath expressions, like $.items[*].id or $..created_at,
+// or JSON Pointers, like /items/0/id.
+// JSONPath supports child (.name, ['name']), index ([0]), wildcard (.* and [*]) and descendant (..name) selectors.
+type JSONOption func(*jsonOptions)
+
+type jsonOptions struct {
+	ignore        []string
+	redactions    []redaction
+	unordered     []string
+	unorderedAll  bool
+	tolerance     float64
+	ignoreParsed  []jsonPath
+	unorderParsed []jsonPath
+}
+
+type redaction struct {
+	placeholder string
+	paths       []string
+	parsed      []jsonPath
+	match       func(s string) bool
+}
+
+// IgnorePaths skips values at paths. Missing and unexpected fields at paths are skipped as well.
+func IgnorePaths(paths ...string) JSONOption {
+	return func(o *jsonOptions) {
+		o.ignore = append(o.ignore, paths...)
+	}
+}
+
+// RedactPaths replaces values at paths with placeholder, like "<id>".
+// Values are replaced in both golden and actual JSON, and before writing golden file in update mode.
+func RedactPaths(placeholder string, paths ...string) JSONOption {
+	return func(o *jsonOptions) {
+		o.redactions = append(o.redactions, redaction{placeholder: placeholder, paths: paths})
+	}
+}
+
+// Redact replaces string values for which match returns true with placeholder.
+// Values are replaced in both golden and actual JSON, and before writing golden file in update mode.
+func Redact(placeholder string, match func(s string) bool) JSONOption {
+	return func(o *jsonOptions) {
+		o.redactions = append(o.redactions, redaction{placeholder: placeholder, match: match})
+	}
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// RedactUUIDs replaces UUID strings with "<uuid>".
+func RedactUUIDs() JSONOption {
+	return Redact("<uuid>", uuidRe.MatchString)
+}
+
+// RedactRFC3339 replaces RFC 3339 timestamps with "<rfc3339>".
+func RedactRFC3339() JSONOption {
+	return Redact("<rfc3339>", func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	})
+}
+
+// UnorderedArrays compares arrays at paths ignoring order of elements.
+// Without paths, it applies to all arrays.
+func UnorderedArrays(paths ...string) JSONOption {
+	return func(o *jsonOptions) {
+		if len(paths) == 0 {
+			o.unorderedAll = true
+		}
+		o.unordered = append(o.unordered, paths...)
+	}
+}
+
+// NumberTolerance treats numbers as equal when they differ by at most delta.
+func NumberTolerance(delta float64) JSONOption {
+	return func(o *jsonOptions) {
+		o.tolerance = delta
+	}
+}
+
+// AssertJSON compares got with the golden file in ./testdata/ as JSON values, see Fixtures.AssertJSON.
+func AssertJSON(t testing.TB, name string, got []byte, opts ...JSONOption) {
+	t.Helper()
+	New(t).AssertJSON(name, got, opts...)
+}
+
+// AssertJSON compares got with the golden file as JSON values, so formatting and order of keys don't matter.
+// Mismatches are reported with JSONPath of the differing values.
+// In update mode, it writes indented and redacted got into the golden file, if it differs from the golden one.
+func (f *Fixtures) AssertJSON(name string, got []byte, opts ...JSONOption) {
+	f.t.Helper()
+
+	f.removeArtifact(f.actualPath(name))
+
+	o, err := newJSONOptions(opts)
+	if err != nil {
+		f.t.Fatalf("invalid JSON option: %s", err)
+	}
+
+	gotValue, err := parseJSON(got)
+	if err != nil {
+		f.t.Fatalf("parse got JSON: %s", err)
+	}
+	gotValue = o.redact(nil, gotValue)
+
+	if updating() {
+		if want, err := f.readFile(name); err == nil {
+			if wantValue, err := parseJSON(want); err == nil && len(o.diff(nil, o.redact(nil, wantValue), gotValue)) == 0 {
+				return
+			}
+		}
+
+		f.write(name, formatJSON(gotValue))
+		return
+	}
+
+	wantValue, err := parseJSON(f.readGolden(name))
+	if err != nil {
+		f.t.Fatalf("parse golden JSON %s: %s", f.Path(name), err)
+	}
+	wantValue = o.redact(nil, wantValue)
+
+	if diffs := o.diff(nil, wantValue, gotValue); len(diffs) > 0 {
+		f.mismatch(name, strings.Join(diffs, "\n")+"\n", formatJSON(gotValue))
+	}
+}
+
+func newJSONOptions(opts []JSONOption) (*jsonOptions, error) {
+	o := &jsonOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var err error
+	if o.ignoreParsed, err = parseJSONPaths(o.ignore); err != nil {
+		return nil, err
+	}
+	if o.unorderParsed, err = parseJSONPaths(o.unordered); err != nil {
+		return nil, err
+	}
+	for i := range o.redactions {
+		if o.redactions[i].parsed, err = parseJSONPaths(o.redactions[i].paths); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// redact returns v with values replaced by placeholders.
+func (o *jsonOptions) redact(p []pathElem, v any) any {
+	for _, r := range o.redactions {
+		if matchAny(r.parsed, p) {
+			return r.placeholder
+		}
+		if s, ok := v.(string); ok && r.match != nil && r.match(s) {
+			return r.placeholder
+		}
+	}
+
+	switch v := v.(type) {
+	case *jsonObject:
+		for _, k := range v.keys {
+			v.values[k] = o.redact(append(p, pathElem{key: k, index: -1}), v.values[k])
+		}
+	case []any:
+		for i := range v {
+			v[i] = o.redact(append(p, pathElem{index: i}), v[i])
+		}
+	}
+
+	return v
+}
+
+// diff returns differences between want and got, one per line, prefixed with their JSONPath.
+func (o *jsonOptions) diff(p []pathElem, want, got any) []string {
+	if matchAny(o.ignoreParsed, p) {
+		return nil
+	}
+
+	switch w := want.(type) {
+	case *jsonObject:
+		g, ok := got.(*jsonObject)
+		if !ok {
+			break
+		}
+
+		var diffs []string
+		for _, k := range w.keys {
+			kp := append(slices.Clip(p), pathElem{key: k, index: -1})
+			gv, ok := g.values[k]
+			if !ok {
+				if !matchAny(o.ignoreParsed, kp) {
+					diffs = append(diffs, fmt.Sprintf("%s: missing, want %s", formatPath(kp), compactJSON(w.values[k])))
+				}
+				continue
+			}
+			diffs = append(diffs, o.diff(kp, w.values[k], gv)...)
+		}
+		for _, k := range g.keys {
+			kp := append(slices.Clip(p), pathElem{key: k, index: -1})
+			if _, ok := w.values[k]; !ok && !matchAny(o.ignoreParsed, kp) {
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", formatPath(kp), compactJSON(g.values[k])))
+			}
+		}
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		if o.unorderedAll || matchAny(o.unorderParsed, p) {
+			return o.diffUnordered(p, w, g)
+		}
+
+		var diffs []string
+		for i := range max(len(w), len(g)) {
+			ip := append(slices.Clip(p), pathElem{index: i})
+			switch {
+			case i >= len(g):
+				diffs = append(diffs, fmt.Sprintf("%s: missing, want %s", formatPath(ip), compactJSON(w[i])))
+			case i >= len(w):
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", formatPath(ip), compactJSON(g[i])))
+			default:
+				diffs = append(diffs, o.diff(ip, w[i], g[i])...)
+			}
+		}
+		return diffs
+	case json.Number:
+		if g, ok := got.(json.Number); ok && o.equalNumbers(w, g) {
+			return nil
+		}
+	default:
+		if want == got {
+			return nil
+		}
+	}
+
+	return []string{fmt.Sprintf("%s: want %s, got %s", formatPath(p), compactJSON(want), compactJSON(got))}
+}
+
+// diffUnordered matches every element of want with an equal element of got.
+func (o *jsonOptions) diffUnordered(p []pathElem, want, got []any) []string {
+	used := make([]bool, len(got))
+	var diffs []string
+	for i, w := range want {
+		ip := append(slices.Clip(p), pathElem{index: i})
+		found := false
+		for j, g := range got {
+			if !used[j] && len(o.diff(ip, w, g)) == 0 {
+				used[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, fmt.Sprintf("%s: missing, want %s", formatPath(ip), compactJSON(w)))
+		}
+	}
+	for j, g := range got {
+		if !used[j] {
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", formatPath(append(slices.Clip(p), pathElem{index: j})), compactJSON(g)))
+		}
+	}
+
+	return diffs
+}
+
+func (o *jsonOptions) equalNumbers(want, got json.Number) bool {
+	if want == got {
+		return true
+	}
+
+	w, err1 := want.Float64()
+	g, err2 := got.Float64()
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	return math.Abs(w-g) <= o.tolerance
+}
+
+// jsonObject is a JSON object which keeps order of keys, so rewritten golden files keep order of the output.
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// parseJSON parses JSON into *jsonObject, []any, json.Number, string, bool or nil.
+func parseJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v, err := parseValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return v, nil
+}
+
+func parseValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{values: make(map[string]any)}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			v, err := parseValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := obj.values[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = v
+		}
+		_, err := dec.Token() // closing brace
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := parseValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token() // closing bracket
+		return arr, err
+	default:
+		return tok, nil
+	}
+}
+
+// formatJSON formats value indented like golden files are written by hand.
+func formatJSON(v any) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, compactJSONBytes(v), "", "    "); err != nil {
+		panic(err) // compact JSON is always valid
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes()
+}
+
+func compactJSON(v any) string {
+	return string(compactJSONBytes(v))
+}
+
+func compactJSONBytes(v any) []byte {
+	var buf bytes.Buffer
+	writeJSON(&buf, v)
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case *jsonObject:
+		buf.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, k)
+			buf.WriteByte(':')
+			writeJSON(buf, v.values[k])
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, e)
+		}
+		buf.WriteByte(']')
+	case string:
+		writeJSONString(buf, v)
+	case json.Number:
+		buf.WriteString(v.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	default:
+		panic(fmt.Sprintf("unexpected JSON value %T", v))
+	}
+}
+
+// writeJSONString writes s without escaping HTML, so placeholders like "<uuid>" stay readable.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	// encoding a string can't fail, it only adds a newline to trim
+	_ = enc.Encode(s)
+	buf.Truncate(buf.Len() - 1)
+}
+
+// pathElem is an element of a concrete path, key of an object or index of an array.
+type pathElem struct {
+	key   string
+	index int // -1 for object keys
+}
+
+func (e pathElem) String() string {
+	if e.index >= 0 {
+		return strconv.Itoa(e.index)
+	}
+	return e.key
+}
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatPath formats path as JSONPath, like $.items[0].id.
+func formatPath(p []pathElem) string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, e := range p {
+		switch {
+		case e.index >= 0:
+			fmt.Fprintf(&b, "[%d]", e.index)
+		case identRe.MatchString(e.key):
+			b.WriteString("." + e.key)
+		default:
+			b.WriteString("['" + strings.ReplaceAll(e.key, "'", `\'`) + "']")
+		}
+	}
+
+	return b.String()
+}
+
+// jsonPath is a parsed path of an option.
+type jsonPath []pathSegment
+
+type pathSegment struct {
+	name       string
+	wildcard   bool
+	descendant bool // matches at any depth below, like ..name
+}
+
+func matchAny(paths []jsonPath, p []pathElem) bool {
+	for _, jp := range paths {
+		if jp.match(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (jp jsonPath) match(p []pathElem) bool {
+	if len(jp) == 0 {
+		return len(p) == 0
+	}
+	if len(p) == 0 {
+		return false
+	}
+
+	seg := jp[0]
+	if seg.wildcard || seg.name == p[0].String() {
+		if jp[1:].match(p[1:]) {
+			return true
+		}
+	}
+	if seg.descendant {
+		return jp.match(p[1:])
+	}
+
+	return false
+}
+
+func parseJSONPaths(paths []string) ([]jsonPath, error) {
+	parsed := make([]jsonPath, 0, len(paths))
+	for _, p := range paths {
+		jp, err := parseJSONPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("parse path %q: %w", p, err)
+		}
+		parsed = append(parsed, jp)
+	}
+
+	return parsed, nil
+}
+
+// parseJSONPath parses JSONPath starting with $ or JSON Pointer (RFC 6901).
+func parseJSONPath(s string) (jsonPath, error) {
+	if s == "" {
+		return jsonPath{}, nil
+	}
+	if strings.HasPrefix(s, "/") {
+		var jp jsonPath
+		for _, tok := range strings.Split(s[1:], "/") {
+			tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+			jp = append(jp, pathSegment{name: tok})
+		}
+		return jp, nil
+	}
+
+	rest, ok := strings.CutPrefix(s, "$")
+	if !ok {
+		return nil, errors.New("must start with $ or /")
+	}
+
+	var jp jsonPath
+	for rest != "" {
+		var seg pathSegment
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			seg.descendant = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				var err error
+				if seg, rest, err = parseBracket(rest); err != nil {
+					return nil, err
+				}
+				seg.descendant = true
+				jp = append(jp, seg)
+				continue
+			}
+			seg.name, rest = cutName(rest)
+		case strings.HasPrefix(rest, "."):
+			seg.name, rest = cutName(rest[1:])
+		case strings.HasPrefix(rest, "["):
+			var err error
+			if seg, rest, err = parseBracket(rest); err != nil {
+				return nil, err
+			}
+			jp = append(jp, seg)
+			continue
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+
+		if seg.name == "" {
+			return nil, errors.New("empty name")
+		}
+		if seg.name == "*" {
+			seg.name, seg.wildcard = "", true
+		}
+		jp = append(jp, seg)
+	}
+
+	return jp, nil
+}
+
+func cutName(s string) (string, string) {
+	i := strings.IndexAny(s, ".[")
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], s[i:]
+}
+
+// parseBracket parses [0], [*], ['name'] or ["name"].
+func parseBracket(s string) (pathSegment, string, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		quote := s[1]
+		end := strings.IndexByte(s[2:], quote)
+		if end < 0 || !strings.HasPrefix(s[2+end+1:], "]") {
+			return pathSegment{}, "", fmt.Errorf("unterminated %q", s)
+		}
+		return pathSegment{name: s[2 : 2+end]}, s[2+end+2:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return pathSegment{}, "", fmt.Errorf("unterminated %q", s)
+	}
+	inner := s[1:end]
+	if inner == "*" {
+		return pathSegment{wildcard: true}, s[end+1:], nil
+	}
+	if _, err := strconv.Atoi(inner); err != nil {
+		return pathSegment{}, "", fmt.Errorf("invalid index %q", inner)
+	}
+
+	return pathSegment{name: inner}, s[end+1:], nil
+}
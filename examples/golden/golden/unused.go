@@ -0,0 +1,204 @@
+package golden
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var checkUnused = flag.Bool("golden.check-unused", false, "report fixtures in testdata which no test used, remove them with -update")
+
+// accessed tracks fixtures used during the test binary run, directories they are in
+// and whether any test using fixtures was skipped.
+var accessed = struct {
+	sync.Mutex
+	files   map[string]bool
+	roots   map[string]bool
+	skipped bool
+}{
+	files: make(map[string]bool),
+	roots: map[string]bool{"testdata": true},
+}
+
+// track marks slash-separated path of the fixture as used.
+func track(name string) {
+	accessed.Lock()
+	defer accessed.Unlock()
+
+	accessed.files[path.Clean(name)] = true
+}
+
+func trackRoot(dir string) {
+	accessed.Lock()
+	defer accessed.Unlock()
+
+	accessed.roots[path.Clean(dir)] = true
+}
+
+// trackSkip records if the test is skipped, as its fixtures look unused then.
+func trackSkip(t testing.TB) {
+	t.Cleanup(func() {
+		if !t.Skipped() {
+			return
+		}
+
+		accessed.Lock()
+		defer accessed.Unlock()
+
+		accessed.skipped = true
+	})
+}
+
+// RunOption configures Run.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	checkUnused bool
+}
+
+// CheckUnused checks unused fixtures without -golden.check-unused flag.
+func CheckUnused() RunOption {
+	return func(o *runOptions) {
+		o.checkUnused = true
+	}
+}
+
+// Run runs tests and, with -golden.check-unused flag, reports files in fixture directories
+// which were not used by any test. It fails the run if there are unused files.
+// In update mode, unused files are removed instead. Use it in TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(golden.Run(m))
+//	}
+//
+// Files written for inspection of failures, *.actual and *.diff.png, are not reported.
+// Check is skipped when not all tests run, as the skipped tests use the rest of the fixtures:
+// with -run, -skip, -list or -short flags, or when a test using fixtures calls t.Skip.
+func Run(m *testing.M, opts ...RunOption) int {
+	o := &runOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	code := m.Run()
+	if code != 0 || !(o.checkUnused || *checkUnused) {
+		return code
+	}
+
+	if reason := partialRun(); reason != "" {
+		fmt.Fprintf(os.Stderr, "golden: not checking unused fixtures, %s\n", reason)
+		return code
+	}
+
+	accessed.Lock()
+	defer accessed.Unlock()
+
+	roots := make([]string, 0, len(accessed.roots))
+	for r := range accessed.roots {
+		roots = append(roots, r)
+	}
+
+	return checkUnusedFiles(os.Stderr, roots, accessed.files, updating())
+}
+
+// partialRun returns why not all tests have run, or empty string if all have.
+func partialRun() string {
+	for _, name := range []string{"test.run", "test.skip"} {
+		if f := flag.Lookup(name); f != nil && f.Value.String() != "" {
+			return "-" + strings.TrimPrefix(name, "test.") + " runs only some tests"
+		}
+	}
+	if f := flag.Lookup("test.list"); f != nil && f.Value.String() != "" {
+		return "-list doesn't run tests"
+	}
+	if testing.Short() {
+		return "-short skips some tests"
+	}
+
+	accessed.Lock()
+	defer accessed.Unlock()
+
+	if accessed.skipped {
+		return "some tests were skipped"
+	}
+
+	return ""
+}
+
+// checkUnusedFiles reports files in roots of the working directory, which are not in used, and returns exit code.
+// If remove is true, unused files and directories left empty are removed instead.
+func checkUnusedFiles(w io.Writer, roots []string, used map[string]bool, remove bool) int {
+	unused, err := unusedFiles(os.DirFS("."), roots, used)
+	if err != nil {
+		fmt.Fprintf(w, "golden: check unused fixtures: %s\n", err)
+		return 1
+	}
+	if len(unused) == 0 {
+		return 0
+	}
+
+	if remove {
+		for _, name := range unused {
+			if err := os.Remove(filepath.FromSlash(name)); err != nil {
+				fmt.Fprintf(w, "golden: remove unused fixture: %s\n", err)
+				return 1
+			}
+			fmt.Fprintf(w, "golden: removed unused fixture %s\n", filepath.FromSlash(name))
+
+			// remove directories left empty, like of a removed case; removing non-empty one fails
+			for dir := path.Dir(name); dir != "." && !slices.Contains(roots, dir); dir = path.Dir(dir) {
+				if os.Remove(filepath.FromSlash(dir)) != nil {
+					break
+				}
+			}
+		}
+		return 0
+	}
+
+	fmt.Fprintln(w, "golden: fixtures not used by any test, run with -update to remove them:")
+	for _, name := range unused {
+		fmt.Fprintf(w, "\t%s\n", filepath.FromSlash(name))
+	}
+
+	return 1
+}
+
+// unusedFiles returns sorted files under roots in fsys, which are not in used.
+func unusedFiles(fsys fs.FS, roots []string, used map[string]bool) ([]string, error) {
+	var unused []string
+	for _, root := range roots {
+		err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && name == root {
+				return fs.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || used[name] || isFailureArtifact(name) {
+				return nil
+			}
+
+			unused = append(unused, name)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slices.Sort(unused)
+	return slices.Compact(unused), nil
+}
+
+func isFailureArtifact(name string) bool {
+	return strings.HasSuffix(name, ".actual") || strings.HasSuffix(name, ".diff.png")
+}
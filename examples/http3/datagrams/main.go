@@ -26,6 +26,7 @@ func main() {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ping-pong", pingPongHandler)
+	// snippet:start server
 	srv := &http3.Server{
 		Addr:    "127.0.0.1:8080",
 		Handler: mux,
@@ -35,6 +36,7 @@ func main() {
 		},
 		EnableDatagrams: true, // enable datagrams support
 	}
+	// snippet:end server
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -61,6 +63,7 @@ func main() {
 	wg.Wait()
 }
 
+// snippet:start handler
 func pingPongHandler(w http.ResponseWriter, r *http.Request) {
 	conn := w.(http3.Hijacker).Connection()
 	select {
@@ -76,11 +79,15 @@ func pingPongHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "datagram support not enabled", http.StatusBadRequest)
 		return
 	}
-	// resposding with OK 200 header
+
+	// responding with OK 200 header
 	w.WriteHeader(http.StatusOK)
+	// snippet:end handler
 	// taking over the HTTP/3 stream
+	// snippet:start takeover
 	streamer := w.(http3.HTTPStreamer)
 	http3Stream := streamer.HTTPStream()
+	// snippet:end takeover
 	defer http3Stream.Close()
 
 	ctx := r.Context()
@@ -132,12 +139,14 @@ func runClient(ctx context.Context) error {
 		RootCAs:    certPool,                    // use the cert pool with server's cert
 		NextProtos: []string{http3.NextProtoH3}, // use HTTP/3 protocol
 	}
+	// snippet:start transport
 	quicConf := &quic.Config{
 		EnableDatagrams: true, // enable QUIC datagrams support
 	}
 	tr := &http3.Transport{
 		EnableDatagrams: true, // enable support for HTTP/3 datagrams
 	}
+	// snippet:end transport
 
 	quicConn, err := quic.DialAddr(ctx, "localhost:8080", tlsConf, quicConf)
 	if err != nil {
@@ -145,6 +154,7 @@ func runClient(ctx context.Context) error {
 	}
 
 	http3Conn := tr.NewClientConn(quicConn)
+	// snippet:start settings
 	// wait for the server's SETTINGS
 	select {
 	case <-http3Conn.ReceivedSettings():
@@ -158,6 +168,7 @@ func runClient(ctx context.Context) error {
 		http3Conn.CloseWithError(http3.ErrCodeNoError, "datagram support not enabled")
 		return fmt.Errorf("server does not support datagrams")
 	}
+	// snippet:end settings
 	defer http3Conn.CloseWithError(http3.ErrCodeNoError, "bye!")
 
 	reqStream, err := http3Conn.OpenRequestStream(ctx)
@@ -166,6 +177,7 @@ func runClient(ctx context.Context) error {
 	}
 	defer reqStream.Close()
 
+	// snippet:start request
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://localhost:8080/ping-pong", http.NoBody)
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
@@ -180,9 +192,10 @@ func runClient(ctx context.Context) error {
 		return fmt.Errorf("read response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK { // checking the server's response to see if we can start datagram exchange
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
+	// snippet:end request
 
 	var (
 		val uint64
@@ -40,20 +40,24 @@ func main() {
 }
 
 func runServer() {
+	// snippet:start handler
 	mux := http.NewServeMux()
 	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
-		// resposding with OK 200 header
+		// respond with OK 200 header
 		w.WriteHeader(http.StatusOK)
-		// taking over the HTTP/3 stream
+		// snippet:start takeover
+		// take over the HTTP/3 stream
 		streamer := w.(http3.HTTPStreamer)
 		http3Stream := streamer.HTTPStream()
 		defer http3Stream.Close()
-		// sending data to the stream
+		// snippet:end takeover
+		// send data to the stream
 		for i := range 10 {
 			fmt.Fprintf(http3Stream, "data chunk #%d\n", i)
 			time.Sleep(100 * time.Millisecond)
 		}
 	})
+	// snippet:end handler
 	srv := &http3.Server{
 		// listen on the port 8080
 		Addr:    "127.0.0.1:8080",
@@ -4,3 +4,8 @@ import "embed"
 
 //go:embed public
 var Public embed.FS
+
+// Related is the related posts index generated by tools/related.
+//
+//go:embed data/related.json
+var Related []byte
@@ -2,4 +2,18 @@ module github.com/dmksnnk/blog
 
 go 1.24.3
 
-require github.com/caarlos0/env/v11 v11.3.1
+require (
+	example.com/golden v0.0.0-00010101000000-000000000000
+	github.com/BurntSushi/toml v1.5.0
+	github.com/caarlos0/env/v11 v11.3.1
+	golang.org/x/image v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.38.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
+)
+
+// golden test helpers are shared with the example of the "Golden tests" post
+replace example.com/golden => ./examples/golden
@@ -0,0 +1,306 @@
+// Package content reads Hugo pages from the content/ directory.
+package content
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Front matter formats.
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// Page is a single Markdown file from content/ directory.
+type Page struct {
+	// Path is slash-separated path relative to content directory, e.g. "blog/Golden tests.md".
+	Path string
+	// Format is the front matter format.
+	Format string
+	// Params is the raw front matter with lowercased keys, as Hugo treats them case-insensitive.
+	Params map[string]any
+	// Body is the Markdown content after front matter.
+	Body string
+	// BodyLine is the line number where body starts.
+	BodyLine int
+
+	frontMatter []string
+}
+
+// ErrNoFrontMatter is returned for pages without front matter.
+var ErrNoFrontMatter = errors.New("no front matter")
+
+// Parse parses page with YAML (---) or TOML (+++) front matter.
+func Parse(name string, data []byte) (Page, error) {
+	p := Page{Path: name}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) == 0 {
+		return p, ErrNoFrontMatter
+	}
+
+	delim := strings.TrimSpace(lines[0])
+	switch delim {
+	case "---":
+		p.Format = FormatYAML
+	case "+++":
+		p.Format = FormatTOML
+	default:
+		return p, ErrNoFrontMatter
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == delim {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return p, fmt.Errorf("front matter is not closed with %q", delim)
+	}
+
+	p.frontMatter = lines[1:end]
+	p.Body = strings.Join(lines[end+1:], "")
+	p.BodyLine = end + 2
+
+	raw := map[string]any{}
+	fm := strings.Join(p.frontMatter, "")
+	switch p.Format {
+	case FormatYAML:
+		if err := yaml.Unmarshal([]byte(fm), &raw); err != nil {
+			return p, fmt.Errorf("parse YAML front matter: %w", err)
+		}
+	case FormatTOML:
+		if _, err := toml.Decode(fm, &raw); err != nil {
+			return p, fmt.Errorf("parse TOML front matter: %w", err)
+		}
+	}
+	p.Params = lowerKeys(raw)
+
+	return p, nil
+}
+
+func lowerKeys(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]any); ok {
+			v = lowerKeys(nested)
+		}
+		out[strings.ToLower(k)] = v
+	}
+
+	return out
+}
+
+// Load parses all Markdown pages in fsys, sorted by path.
+func Load(fsys fs.FS) ([]Page, error) {
+	var pages []Page
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".md" {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		p, err := Parse(name, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		pages = append(pages, p)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Path < pages[j].Path })
+
+	return pages, nil
+}
+
+// Line returns line number of the front matter key in the file, e.g. "cover.image".
+// It returns line of the front matter start if the key is not found.
+func (p Page) Line(key string) int {
+	parts := strings.Split(key, ".")
+	part := 0
+	indent := -1
+	for i, l := range p.frontMatter {
+		trimmed := strings.TrimLeft(l, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lineIndent := len(l) - len(trimmed)
+		if part > 0 && lineIndent <= indent && !strings.HasPrefix(trimmed, "[") {
+			// left the parent block, YAML only
+			if p.Format == FormatYAML {
+				part, indent = 0, -1
+			}
+		}
+
+		if keyRe(parts[part], p.Format).MatchString(trimmed) {
+			if part == len(parts)-1 {
+				return i + 2 // front matter starts on the second line
+			}
+			part++
+			indent = lineIndent
+		}
+	}
+
+	return 1
+}
+
+func keyRe(key, format string) *regexp.Regexp {
+	k := regexp.QuoteMeta(key)
+	if format == FormatTOML {
+		return regexp.MustCompile(`(?i)^(\[\s*` + k + `\s*\]|["']?` + k + `["']?\s*=)`)
+	}
+
+	return regexp.MustCompile(`(?i)^["']?` + k + `["']?\s*:`)
+}
+
+// IsSection reports whether the page is a section or term list page (_index.md).
+func (p Page) IsSection() bool {
+	return path.Base(p.Path) == "_index.md"
+}
+
+// IsBundle reports whether the page is a leaf bundle (index.md), which has its resources in the same directory.
+func (p Page) IsBundle() bool {
+	return path.Base(p.Path) == "index.md"
+}
+
+// Section returns top-level section of the page, e.g. "blog".
+func (p Page) Section() string {
+	section, _, ok := strings.Cut(p.Path, "/")
+	if !ok {
+		return ""
+	}
+
+	return section
+}
+
+// String returns string parameter.
+func (p Page) String(key string) string {
+	s, _ := p.Param(key).(string)
+	return s
+}
+
+// Strings returns list of strings parameter, like tags.
+func (p Page) Strings(key string) []string {
+	switch v := p.Param(key).(type) {
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	}
+
+	return nil
+}
+
+// Param returns front matter parameter by dotted key, e.g. "cover.image".
+func (p Page) Param(key string) any {
+	var v any = p.Params
+	for _, part := range strings.Split(strings.ToLower(key), ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+
+	return v
+}
+
+// Title returns page title.
+func (p Page) Title() string { return p.String("title") }
+
+// Slug returns page slug.
+func (p Page) Slug() string { return p.String("slug") }
+
+// Draft reports whether page is a draft.
+func (p Page) Draft() bool {
+	d, _ := p.Param("draft").(bool)
+	return d
+}
+
+// Date returns page date.
+func (p Page) Date() (time.Time, error) {
+	switch v := p.Param("date").(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	case nil:
+		return time.Time{}, errors.New("no date")
+	default:
+		return time.Time{}, fmt.Errorf("unexpected date type %T", v)
+	}
+}
+
+// Description returns description, or summary if there is no description.
+func (p Page) Description() string {
+	if d := p.String("description"); d != "" {
+		return d
+	}
+
+	return strings.TrimSpace(p.String("summary"))
+}
+
+// URLPath returns path of the rendered page, as Hugo builds it with default permalinks, e.g. "/blog/golden-tests/".
+func (p Page) URLPath() string {
+	dir := path.Dir(p.Path)
+	if p.IsSection() {
+		if dir == "." {
+			return "/"
+		}
+		return "/" + Urlize(dir) + "/"
+	}
+
+	name := strings.TrimSuffix(path.Base(p.Path), ".md")
+	if p.IsBundle() {
+		name = path.Base(dir)
+		dir = path.Dir(dir)
+	}
+	if slug := p.Slug(); slug != "" {
+		name = slug
+	}
+
+	return path.Clean("/"+Urlize(path.Join(dir, name))) + "/"
+}
+
+var nonURLChars = regexp.MustCompile(`[^\p{L}\p{N}/_.-]+`)
+
+// Urlize converts string to URL path, like Hugo's urlize function: "HTTP3 Client" -> "http3-client".
+func Urlize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.ReplaceAll(s, " ", "-")
+	return nonURLChars.ReplaceAllString(s, "")
+}
@@ -0,0 +1,75 @@
+package content_test
+
+import (
+	"testing"
+
+	"github.com/dmksnnk/blog/internal/content"
+)
+
+func TestParse(t *testing.T) {
+	page, err := content.Parse("blog/http3/1. Writing HTTP3 Server.md", []byte(`---
+date: '2025-05-08T18:44:39+02:00'
+title: 'Writing HTTP/3 Server'
+slug: http3-server
+showToc: true
+cover:
+    image: 'images/http3.svg'
+tags:
+  - HTTP3
+series:
+  - "HTTP3"
+---
+
+Body.
+`))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	if page.Title() != "Writing HTTP/3 Server" {
+		t.Errorf("unexpected title %q", page.Title())
+	}
+	if got := page.Param("showtoc"); got != true {
+		t.Errorf("expected case-insensitive params, got showtoc=%v", got)
+	}
+	if got := page.Strings("series"); len(got) != 1 || got[0] != "HTTP3" {
+		t.Errorf("unexpected series %v", got)
+	}
+	if got := page.URLPath(); got != "/blog/http3/http3-server/" {
+		t.Errorf("unexpected URL path %q", got)
+	}
+	if got := page.Line("cover.image"); got != 7 {
+		t.Errorf("expected cover.image on line 7, got %d", got)
+	}
+	if page.BodyLine != 13 || page.Body != "\nBody.\n" {
+		t.Errorf("unexpected body at line %d: %q", page.BodyLine, page.Body)
+	}
+}
+
+func TestURLPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		front string
+		want  string
+	}{
+		{path: "_index.md", want: "/"},
+		{path: "series/http3/_index.md", want: "/series/http3/"},
+		{path: "blog/Golden tests.md", front: "slug: golden-tests", want: "/blog/golden-tests/"},
+		{path: "blog/Test smell.md", want: "/blog/test-smell/"},
+		{path: "blog/webview/index.md", front: "slug: go-webview-gui", want: "/blog/go-webview-gui/"},
+		{path: "blog/webview/index.md", want: "/blog/webview/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			page, err := content.Parse(tt.path, []byte("---\n"+tt.front+"\n---\n"))
+			if err != nil {
+				t.Fatalf("parse: %s", err)
+			}
+
+			if got := page.URLPath(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Package og renders Open Graph preview images of posts.
+package og
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Image size recommended by Open Graph consumers.
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// version is a part of the card key, bump it when rendering changes to invalidate caches.
+const version = "1"
+
+const (
+	padding     = 80
+	accentWidth = 16
+	maxLines    = 3
+)
+
+var (
+	background = color.RGBA{R: 0x1d, G: 0x1e, B: 0x20, A: 0xff}
+	foreground = color.RGBA{R: 0xda, G: 0xda, B: 0xdb, A: 0xff}
+	secondary  = color.RGBA{R: 0x9b, G: 0x9c, B: 0x9d, A: 0xff}
+	accent     = color.RGBA{R: 0x88, G: 0xc0, B: 0xd0, A: 0xff}
+)
+
+// Card is the content of an image.
+type Card struct {
+	Title    string
+	Tags     []string
+	Date     time.Time
+	SiteName string
+}
+
+// Key returns hash of the card content, it changes when the content or rendering changes.
+func (c Card) Key() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s", version, c.Title, strings.Join(c.Tags, "\x01"), c.Date.Format(time.DateOnly), c.SiteName)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Renderer renders cards with embedded Go fonts.
+type Renderer struct {
+	bold    *opentype.Font
+	regular *opentype.Font
+}
+
+// NewRenderer parses embedded fonts.
+func NewRenderer() (*Renderer, error) {
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("parse bold font: %w", err)
+	}
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("parse regular font: %w", err)
+	}
+
+	return &Renderer{bold: bold, regular: regular}, nil
+}
+
+// Render writes card as PNG image.
+func (r *Renderer) Render(w io.Writer, c Card) error {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, accentWidth, Height), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	textWidth := Width - 2*padding
+
+	siteFace, err := r.face(r.regular, 32)
+	if err != nil {
+		return err
+	}
+	defer siteFace.Close()
+	drawText(img, siteFace, accent, padding, padding+32, c.SiteName)
+
+	titleFace, lines, err := r.fitTitle(c.Title, textWidth)
+	if err != nil {
+		return err
+	}
+	defer titleFace.Close()
+	lineHeight := titleFace.Metrics().Height.Ceil()
+	y := padding + 32 + 60 + titleFace.Metrics().Ascent.Ceil()
+	for _, l := range lines {
+		drawText(img, titleFace, foreground, padding, y, l)
+		y += lineHeight
+	}
+
+	metaFace, err := r.face(r.regular, 30)
+	if err != nil {
+		return err
+	}
+	defer metaFace.Close()
+	if len(c.Tags) > 0 {
+		tags := "#" + strings.Join(c.Tags, "  #")
+		drawText(img, metaFace, accent, padding, Height-padding-48, truncate(metaFace, tags, textWidth))
+	}
+	if !c.Date.IsZero() {
+		drawText(img, metaFace, secondary, padding, Height-padding, c.Date.Format("January 2, 2006"))
+	}
+
+	return png.Encode(w, img)
+}
+
+func (r *Renderer) face(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("create font face: %w", err)
+	}
+
+	return face, nil
+}
+
+// fitTitle picks the largest font size at which title fits into maxLines, truncating it if it doesn't fit at all.
+func (r *Renderer) fitTitle(title string, width int) (font.Face, []string, error) {
+	sizes := []float64{76, 68, 60, 52}
+	for i, size := range sizes {
+		face, err := r.face(r.bold, size)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lines := wrap(face, title, width)
+		if len(lines) <= maxLines {
+			return face, lines, nil
+		}
+		if i == len(sizes)-1 {
+			lines = lines[:maxLines]
+			lines[maxLines-1] = truncate(face, lines[maxLines-1]+" …", width)
+			return face, lines, nil
+		}
+
+		face.Close()
+	}
+
+	panic("unreachable")
+}
+
+// wrap splits text into lines by words, so each line fits into width.
+func wrap(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+
+		if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// truncate shortens text with ellipsis to fit into width.
+func truncate(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		s := strings.TrimRight(string(runes), " ") + "…"
+		if font.MeasureString(face, s).Ceil() <= width {
+			return s
+		}
+	}
+
+	return ""
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
@@ -0,0 +1,72 @@
+package og_test
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/og"
+)
+
+func TestRender(t *testing.T) {
+	r, err := og.NewRenderer()
+	if err != nil {
+		t.Fatalf("create renderer: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		card og.Card
+	}{
+		{
+			name: "post",
+			card: og.Card{Title: "Golden Tests", Tags: []string{"Go", "testing"}, Date: time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC), SiteName: "getpid.dev"},
+		},
+		{
+			name: "long title",
+			card: og.Card{Title: strings.Repeat("Optimistic Elasticsearch updates ", 10), SiteName: "getpid.dev"},
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := r.Render(&buf, tt.card); err != nil {
+				t.Fatalf("render: %s", err)
+			}
+
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("decode PNG: %s", err)
+			}
+			if size := img.Bounds().Size(); size.X != og.Width || size.Y != og.Height {
+				t.Errorf("expected %dx%d image, got %s", og.Width, og.Height, size)
+			}
+		})
+	}
+}
+
+func TestCardKey(t *testing.T) {
+	card := og.Card{Title: "Golden Tests", Tags: []string{"Go", "testing"}, SiteName: "getpid.dev"}
+
+	if card.Key() != card.Key() {
+		t.Fatalf("expected key to be stable")
+	}
+
+	changed := card
+	changed.Tags = []string{"Go", "testing", "API"}
+	if changed.Key() == card.Key() {
+		t.Errorf("expected key to change with tags")
+	}
+
+	changed = card
+	changed.Title = "Golden Tests 2"
+	if changed.Key() == card.Key() {
+		t.Errorf("expected key to change with title")
+	}
+}
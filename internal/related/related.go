@@ -0,0 +1,264 @@
+// Package related finds related posts by shared tags, series and similarity of their text.
+package related
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dmksnnk/blog/internal/content"
+)
+
+// Post is a related post.
+type Post struct {
+	Path        string    `json:"path"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Date        time.Time `json:"date"`
+	// Score is the similarity to the post, from 0 to 1.
+	Score float64 `json:"score"`
+}
+
+// Index maps URL path of a post, e.g. "/blog/golden-tests/", to its related posts, most related first.
+type Index map[string][]Post
+
+// Lookup returns related posts for URL path. Path may omit the trailing slash.
+func (idx Index) Lookup(path string) ([]Post, bool) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	posts, ok := idx[path]
+	return posts, ok
+}
+
+// Weights are weights of similarity components, they should sum up to 1.
+type Weights struct {
+	// Tags is the weight of Jaccard similarity of tags.
+	Tags float64
+	// Series is the weight of being in the same series.
+	Series float64
+	// Text is the weight of TF-IDF cosine similarity of titles and bodies.
+	Text float64
+}
+
+// Options configure Build.
+type Options struct {
+	Weights Weights
+	// Limit is the maximum number of related posts per post.
+	Limit int
+	// MinScore is the minimum score of a related post.
+	MinScore float64
+}
+
+// DefaultOptions are used by the site.
+var DefaultOptions = Options{
+	Weights:  Weights{Tags: 0.35, Series: 0.25, Text: 0.4},
+	Limit:    3,
+	MinScore: 0.1,
+}
+
+type document struct {
+	page   content.Page
+	date   time.Time
+	tags   map[string]bool
+	series map[string]bool
+	vector map[string]float64
+}
+
+// Build builds index of related posts. Sections, drafts and special pages (with layout) are skipped.
+func Build(pages []content.Page, opts Options) Index {
+	var docs []*document
+	for _, p := range pages {
+		if p.IsSection() || p.Draft() || p.String("layout") != "" {
+			continue
+		}
+
+		date, _ := p.Date()
+		docs = append(docs, &document{
+			page:   p,
+			date:   date,
+			tags:   set(p.Strings("tags")),
+			series: set(p.Strings("series")),
+		})
+	}
+
+	vectorize(docs)
+
+	idx := make(Index, len(docs))
+	for _, doc := range docs {
+		posts := []Post{} // posts without related ones are still in the index
+		for _, other := range docs {
+			if other == doc {
+				continue
+			}
+
+			score := opts.Weights.Tags*jaccard(doc.tags, other.tags) +
+				opts.Weights.Series*overlap(doc.series, other.series) +
+				opts.Weights.Text*cosine(doc.vector, other.vector)
+			score = math.Round(score*1000) / 1000 // stable output, small float differences don't matter
+			if score < opts.MinScore {
+				continue
+			}
+
+			posts = append(posts, Post{
+				Path:        other.page.URLPath(),
+				Title:       other.page.Title(),
+				Description: other.page.Description(),
+				Date:        other.date,
+				Score:       score,
+			})
+		}
+
+		sort.Slice(posts, func(i, j int) bool {
+			if posts[i].Score != posts[j].Score {
+				return posts[i].Score > posts[j].Score
+			}
+			if !posts[i].Date.Equal(posts[j].Date) {
+				return posts[i].Date.After(posts[j].Date) // newer first
+			}
+			return posts[i].Path < posts[j].Path
+		})
+		if opts.Limit > 0 && len(posts) > opts.Limit {
+			posts = posts[:opts.Limit]
+		}
+
+		idx[doc.page.URLPath()] = posts
+	}
+
+	return idx
+}
+
+// vectorize computes normalized TF-IDF vectors of documents.
+func vectorize(docs []*document) {
+	terms := make([]map[string]int, len(docs))
+	df := make(map[string]int)
+	for i, doc := range docs {
+		terms[i] = make(map[string]int)
+		for _, t := range tokenize(doc.page.Title() + "\n" + doc.page.Description() + "\n" + doc.page.Body) {
+			if terms[i][t] == 0 {
+				df[t]++
+			}
+			terms[i][t]++
+		}
+	}
+
+	n := float64(len(docs))
+	for i, doc := range docs {
+		doc.vector = make(map[string]float64, len(terms[i]))
+		var norm float64
+		for t, count := range terms[i] {
+			idf := math.Log((1+n)/(1+float64(df[t]))) + 1 // smoothed, so terms in all documents still count a bit
+			w := (1 + math.Log(float64(count))) * idf     // sublinear TF, long posts don't dominate
+			doc.vector[t] = w
+			norm += w * w
+		}
+
+		if norm == 0 {
+			continue
+		}
+		norm = math.Sqrt(norm)
+		for t := range doc.vector {
+			doc.vector[t] /= norm
+		}
+	}
+}
+
+var (
+	codeBlockRe = regexp.MustCompile("(?ms)^\\s*(```|~~~).*?^\\s*(```|~~~)")
+	shortcodeRe = regexp.MustCompile(`{{[<%].*?[%>]}}`)
+	linkURLRe   = regexp.MustCompile(`\]\([^)]*\)`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]+>`)
+)
+
+// tokenize splits Markdown text into lowercase terms. Code blocks, links and stop words are dropped,
+// so similarity is based on what posts are about, not on common words or code.
+func tokenize(text string) []string {
+	text = codeBlockRe.ReplaceAllString(text, " ")
+	text = shortcodeRe.ReplaceAllString(text, " ")
+	text = linkURLRe.ReplaceAllString(text, "]")
+	text = htmlTagRe.ReplaceAllString(text, " ")
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, w := range words {
+		if len(w) < 3 || stopWords[w] || isNumber(w) {
+			continue
+		}
+		tokens = append(tokens, w)
+	}
+
+	return tokens
+}
+
+func isNumber(s string) bool {
+	return strings.TrimFunc(s, unicode.IsDigit) == ""
+}
+
+func set(values []string) map[string]bool {
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[strings.ToLower(v)] = true
+	}
+
+	return m
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for k := range a {
+		if b[k] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// overlap returns 1 if a and b have common elements.
+func overlap(a, b map[string]bool) float64 {
+	for k := range a {
+		if b[k] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func cosine(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	var dot float64
+	for t, w := range a {
+		dot += w * b[t]
+	}
+
+	return dot // vectors are normalized
+}
+
+var stopWords = set(strings.Fields(`
+	about above after again against all also and any are because been before being below
+	between both but can could did does doing down during each few first for from further get
+	gets got had has have having her here hers herself him himself his how into its itself just
+	know let lets like make makes more most much must need new next not now off once one only
+	other our ours ourselves out over own same see she should some such than that the their
+	theirs them themselves then there these they thing things this those through too two under
+	until use used uses using very want was way well were what when where which while who whom
+	why will with would you your yours yourself yourselves
+`))
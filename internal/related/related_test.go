@@ -0,0 +1,73 @@
+package related_test
+
+import (
+	"testing"
+
+	"github.com/dmksnnk/blog/internal/content"
+	"github.com/dmksnnk/blog/internal/related"
+)
+
+func TestBuild(t *testing.T) {
+	pages := []content.Page{
+		page(t, "blog/_index.md", "title: Blog", ""),
+		page(t, "blog/golden.md", "title: Golden tests\nslug: golden-tests\ntags: [Go, testing]",
+			"Golden files store expected output of tests. Update golden files with a flag."),
+		page(t, "blog/smell.md", "title: Test smell\nslug: test-smell\ntags: [testing]",
+			"Tests with too many mocks smell. Prefer testing output, like golden files."),
+		page(t, "blog/http3/server.md", "title: HTTP/3 server\nslug: http3-server\ntags: [Go, HTTP3]\nseries: [HTTP3]",
+			"QUIC streams carry HTTP/3 requests.\n\n```go\nfunc tests() {}\n```\n"),
+		page(t, "blog/http3/client.md", "title: HTTP/3 client\nslug: http3-client\ntags: [Go, HTTP3]\nseries: [HTTP3]",
+			"Client opens QUIC connection and sends HTTP/3 requests."),
+		page(t, "blog/draft.md", "title: Draft\nslug: draft\ndraft: true\ntags: [testing]", "Golden tests draft."),
+	}
+
+	idx := related.Build(pages, related.Options{
+		Weights:  related.Weights{Tags: 0.35, Series: 0.25, Text: 0.4},
+		Limit:    1,
+		MinScore: 0.1,
+	})
+
+	want := map[string]string{
+		"/blog/golden-tests/":       "/blog/test-smell/",
+		"/blog/test-smell/":         "/blog/golden-tests/",
+		"/blog/http3/http3-server/": "/blog/http3/http3-client/",
+		"/blog/http3/http3-client/": "/blog/http3/http3-server/",
+	}
+	if len(idx) != len(want) {
+		t.Fatalf("expected %d posts in index, got %d: %v", len(want), len(idx), idx)
+	}
+
+	for path, wantRelated := range want {
+		posts, ok := idx.Lookup(path)
+		if !ok {
+			t.Fatalf("expected %s in index", path)
+		}
+		if len(posts) != 1 || posts[0].Path != wantRelated {
+			t.Errorf("expected %s to be related to %s, got %v", wantRelated, path, posts)
+		}
+		if posts[0].Score <= 0 || posts[0].Score > 1 {
+			t.Errorf("expected score in (0, 1], got %f", posts[0].Score)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	idx := related.Index{"/blog/golden-tests/": {}}
+
+	for _, path := range []string{"/blog/golden-tests/", "/blog/golden-tests", "blog/golden-tests"} {
+		if _, ok := idx.Lookup(path); !ok {
+			t.Errorf("expected %q to be found", path)
+		}
+	}
+}
+
+func page(t *testing.T, name, front, body string) content.Page {
+	t.Helper()
+
+	p, err := content.Parse(name, []byte("---\n"+front+"\n---\n"+body))
+	if err != nil {
+		t.Fatalf("parse %s: %s", name, err)
+	}
+
+	return p
+}
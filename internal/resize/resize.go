@@ -0,0 +1,83 @@
+// Package resize scales down PNG and JPEG images.
+package resize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxPixels limits size of source images, so a small file can't make decoder allocate gigabytes.
+const MaxPixels = 50_000_000
+
+var (
+	// ErrUnsupported is returned for images other than PNG or JPEG and for too large images.
+	ErrUnsupported = errors.New("unsupported image format")
+	// ErrInvalid is returned for images, which can't be decoded.
+	ErrInvalid = errors.New("invalid image")
+)
+
+// ContentType returns content type of PNG or JPEG image, detected by its content.
+func ContentType(src []byte) (string, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return "", fmt.Errorf("%w: decode image config: %w", ErrInvalid, err)
+	}
+
+	switch format {
+	case "png":
+		return "image/png", nil
+	case "jpeg":
+		return "image/jpeg", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, format)
+	}
+}
+
+// Resize scales image down to width, keeping aspect ratio, and encodes it in the source format.
+// Quality is used for JPEG only. Images narrower than width are re-encoded without scaling.
+// It returns encoded image and its content type.
+func Resize(src []byte, width, quality int) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: decode image config: %w", ErrInvalid, err)
+	}
+	if format != "png" && format != "jpeg" {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupported, format)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", fmt.Errorf("%w: image is too large: %dx%d", ErrUnsupported, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: decode image: %w", ErrInvalid, err)
+	}
+
+	if b := img.Bounds(); b.Dx() > width {
+		height := max(1, b.Dy()*width/b.Dx())
+		dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("encode PNG: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	default:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("encode JPEG: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+}
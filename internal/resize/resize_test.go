@@ -0,0 +1,101 @@
+package resize_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/dmksnnk/blog/internal/resize"
+)
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name        string
+		encode      func(*bytes.Buffer, image.Image) error
+		width       int
+		wantSize    image.Point
+		contentType string
+	}{
+		{
+			name:        "png",
+			encode:      func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) },
+			width:       100,
+			wantSize:    image.Pt(100, 50),
+			contentType: "image/png",
+		},
+		{
+			name:        "jpeg",
+			encode:      func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) },
+			width:       50,
+			wantSize:    image.Pt(50, 25),
+			contentType: "image/jpeg",
+		},
+		{
+			name:        "no upscale",
+			encode:      func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) },
+			width:       1000,
+			wantSize:    image.Pt(400, 200),
+			contentType: "image/png",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var src bytes.Buffer
+			if err := tt.encode(&src, testImage(400, 200)); err != nil {
+				t.Fatalf("encode source: %s", err)
+			}
+
+			data, contentType, err := resize.Resize(src.Bytes(), tt.width, 75)
+			if err != nil {
+				t.Fatalf("resize: %s", err)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("expected content type %s, got %s", tt.contentType, contentType)
+			}
+
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decode result: %s", err)
+			}
+			if got := img.Bounds().Size(); got != tt.wantSize {
+				t.Errorf("expected size %s, got %s", tt.wantSize, got)
+			}
+		})
+	}
+}
+
+func TestResizeUnsupported(t *testing.T) {
+	var src bytes.Buffer
+	if err := gif.Encode(&src, testImage(10, 10), nil); err != nil {
+		t.Fatalf("encode source: %s", err)
+	}
+
+	_, _, err := resize.Resize(src.Bytes(), 10, 75)
+	if !errors.Is(err, resize.ErrUnsupported) {
+		t.Fatalf("expected unsupported error, got %v", err)
+	}
+	if _, err := resize.ContentType(src.Bytes()); !errors.Is(err, resize.ErrUnsupported) {
+		t.Fatalf("expected unsupported error of content type, got %v", err)
+	}
+
+	if _, _, err := resize.Resize([]byte("not an image"), 10, 75); !errors.Is(err, resize.ErrInvalid) {
+		t.Fatalf("expected invalid image error, got %v", err)
+	}
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		for y := range h {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,143 @@
+// Package rss parses RSS 2.0 feeds generated by Hugo.
+package rss
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Channel is the feed.
+type Channel struct {
+	Title         string
+	Link          string
+	Description   string
+	Language      string
+	LastBuildDate time.Time
+	Items         []Item
+}
+
+// Item is a post of the feed.
+type Item struct {
+	Title string
+	Link  string
+	// GUID identifies the item, it is the link if the feed doesn't have one.
+	GUID        string
+	PubDate     time.Time
+	Description string
+	Categories  []string
+	// Image is the cover image URL, from Media RSS content or an image enclosure.
+	Image string
+}
+
+type rssDocument struct {
+	Channel struct {
+		Title string `xml:"title"`
+		// Links include atom:link of Hugo, the channel link is the one without namespace.
+		Links []struct {
+			XMLName xml.Name
+			URL     string `xml:",chardata"`
+		} `xml:"link"`
+		Description   string    `xml:"description"`
+		Language      string    `xml:"language"`
+		LastBuildDate string    `xml:"lastBuildDate"`
+		Items         []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+	Media       []struct {
+		URL    string `xml:"url,attr"`
+		Medium string `xml:"medium,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	Enclosure struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+}
+
+func (it rssItem) image() string {
+	for _, m := range it.Media {
+		if m.Medium == "image" || strings.HasPrefix(m.Type, "image/") {
+			return m.URL
+		}
+	}
+	if strings.HasPrefix(it.Enclosure.Type, "image/") {
+		return it.Enclosure.URL
+	}
+
+	return ""
+}
+
+// Parse parses RSS 2.0 feed.
+func Parse(r io.Reader) (*Channel, error) {
+	var doc rssDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode RSS: %w", err)
+	}
+
+	ch := &Channel{
+		Title:       doc.Channel.Title,
+		Description: doc.Channel.Description,
+		Language:    doc.Channel.Language,
+	}
+
+	for _, l := range doc.Channel.Links {
+		if l.XMLName.Space == "" {
+			ch.Link = strings.TrimSpace(l.URL)
+		}
+	}
+
+	if doc.Channel.LastBuildDate != "" {
+		t, err := parseDate(doc.Channel.LastBuildDate)
+		if err != nil {
+			return nil, fmt.Errorf("parse lastBuildDate: %w", err)
+		}
+		ch.LastBuildDate = t
+	}
+
+	for _, it := range doc.Channel.Items {
+		item := Item{
+			Title:       strings.TrimSpace(it.Title),
+			Link:        strings.TrimSpace(it.Link),
+			GUID:        strings.TrimSpace(it.GUID),
+			Description: strings.TrimSpace(it.Description),
+			Categories:  it.Categories,
+			Image:       it.image(),
+		}
+		if item.GUID == "" {
+			item.GUID = item.Link
+		}
+		if it.PubDate != "" {
+			t, err := parseDate(it.PubDate)
+			if err != nil {
+				return nil, fmt.Errorf("parse pubDate of %s: %w", item.GUID, err)
+			}
+			item.PubDate = t
+		}
+
+		ch.Items = append(ch.Items, item)
+	}
+
+	return ch, nil
+}
+
+// parseDate parses RFC 1123 date, Hugo uses numeric zones, other generators use named ones.
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	t, err := time.Parse(time.RFC1123Z, s)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC1123, s)
+}
@@ -0,0 +1,79 @@
+package rss_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/rss"
+)
+
+func TestParse(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Software Engineering &amp; Personal Thoughts</title>
+    <link>https://getpid.dev/</link>
+    <atom:link href="https://getpid.dev/index.xml" rel="self" type="application/rss+xml" />
+    <lastBuildDate>Thu, 04 Jun 2025 18:27:15 +0200</lastBuildDate>
+    <item>
+      <title>Golden Tests</title>
+      <link>https://getpid.dev/blog/golden-tests/</link>
+      <pubDate>Wed, 04 Jun 2025 18:27:15 +0200</pubDate>
+      <guid>https://getpid.dev/blog/golden-tests/</guid>
+      <description>Use golden files for testing APIs</description>
+      <category>Go</category>
+      <category>testing</category>
+      <media:content url="https://getpid.dev/images/golden-brick.svg" medium="image" />
+    </item>
+    <item>
+      <title>No GUID</title>
+      <link>https://getpid.dev/blog/no-guid/</link>
+      <pubDate>Wed, 28 May 2025 20:02:23 GMT</pubDate>
+      <enclosure url="https://getpid.dev/cover.png" length="1024" type="image/png" />
+    </item>
+  </channel>
+</rss>`
+
+	ch, err := rss.Parse(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	if ch.Title != "Software Engineering & Personal Thoughts" {
+		t.Errorf("unexpected title %q", ch.Title)
+	}
+	if ch.Link != "https://getpid.dev/" {
+		t.Errorf("expected channel link, got %q", ch.Link)
+	}
+	if want := time.Date(2025, 6, 4, 16, 27, 15, 0, time.UTC); !ch.LastBuildDate.Equal(want) {
+		t.Errorf("expected last build date %s, got %s", want, ch.LastBuildDate)
+	}
+	if len(ch.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(ch.Items))
+	}
+
+	item := ch.Items[0]
+	if item.Description != "Use golden files for testing APIs" || len(item.Categories) != 2 {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if item.Image != "https://getpid.dev/images/golden-brick.svg" {
+		t.Errorf("expected image from media content, got %q", item.Image)
+	}
+	if ch.Items[1].Image != "https://getpid.dev/cover.png" {
+		t.Errorf("expected image from enclosure, got %q", ch.Items[1].Image)
+	}
+	if ch.Items[1].GUID != "https://getpid.dev/blog/no-guid/" {
+		t.Errorf("expected link as GUID, got %q", ch.Items[1].GUID)
+	}
+	if want := time.Date(2025, 5, 28, 20, 2, 23, 0, time.UTC); !ch.Items[1].PubDate.Equal(want) {
+		t.Errorf("expected pub date %s, got %s", want, ch.Items[1].PubDate)
+	}
+}
+
+func TestParseInvalidDate(t *testing.T) {
+	feed := `<rss><channel><item><link>https://getpid.dev/</link><pubDate>yesterday</pubDate></item></channel></rss>`
+	if _, err := rss.Parse(strings.NewReader(feed)); err == nil {
+		t.Fatal("expected error for invalid date")
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/content"
+)
+
+type severity string
+
+const (
+	severityError   severity = "error"
+	severityWarning severity = "warning"
+)
+
+type diagnostic struct {
+	Path     string
+	Line     int
+	Severity severity
+	Message  string
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", d.Path, d.Line, d.Severity, d.Message)
+}
+
+// knownKeys are front matter keys used by Hugo and PaperMod theme, lowercased.
+var knownKeys = map[string][]string{
+	"": {
+		"aliases", "author", "build", "canonicalurl", "cascade", "comments", "cover", "date",
+		"description", "disableanchoredheadings", "disableshare", "draft", "editpost", "expirydate",
+		"headless", "hideauthor", "hidefooter", "hidemeta", "hidesummary", "images", "keywords",
+		"lastmod", "layout", "linktitle", "markup", "outputs", "params", "publishdate",
+		"robotsnoindex", "searchhidden", "series", "showbreadcrumbs", "showcanonicallink",
+		"showcodecopybuttons", "showpostnavlinks", "showreadingtime", "showrssbuttoninsectiontermlist",
+		"showsharebuttons", "showtoc", "showwordcount", "slug", "summary", "tags", "title", "tocopen",
+		"type", "url", "usehugotoc", "weight",
+	},
+	"cover":    {"alt", "caption", "hidden", "hiddeninlist", "hiddeninsingle", "image", "relative"},
+	"editpost": {"appendfilepath", "disabled", "text", "url"},
+}
+
+// linter checks front matter of content pages.
+type linter struct {
+	// assets is the Hugo assets directory, cover images are resolved from it.
+	assets fs.FS
+	// content is the Hugo content directory, page bundle resources are resolved from it.
+	content fs.FS
+	tags    []string
+	series  []string
+	now     time.Time
+
+	diagnostics []diagnostic
+}
+
+func (l *linter) report(p content.Page, key string, sev severity, format string, args ...any) {
+	line := 1
+	if key != "" {
+		line = p.Line(key)
+	}
+
+	l.diagnostics = append(l.diagnostics, diagnostic{
+		Path:     p.Path,
+		Line:     line,
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint checks pages and returns found problems sorted by file and line.
+func (l *linter) Lint(pages []content.Page) []diagnostic {
+	slugs := make(map[string]content.Page)
+	for _, p := range pages {
+		l.lintPage(p)
+
+		if slug := p.Slug(); slug != "" && !p.IsSection() {
+			if other, ok := slugs[slug]; ok {
+				l.report(p, "slug", severityError, "slug %q is already used by %s", slug, other.Path)
+				continue
+			}
+			slugs[slug] = p
+		}
+	}
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+
+	return l.diagnostics
+}
+
+func (l *linter) lintPage(p content.Page) {
+	l.lintKeys(p, "", p.Params)
+
+	if p.IsSection() {
+		if p.Path != "_index.md" && p.Title() == "" { // home page takes title from site config
+			l.report(p, "", severityError, "missing required field %q", "title")
+		}
+		return
+	}
+
+	if p.String("layout") != "" { // special pages, like search
+		if p.Title() == "" {
+			l.report(p, "", severityError, "missing required field %q", "title")
+		}
+		return
+	}
+
+	for _, key := range []string{"title", "slug", "date"} {
+		if p.Param(key) == nil {
+			l.report(p, "", severityError, "missing required field %q", key)
+		}
+	}
+	if len(p.Strings("tags")) == 0 {
+		l.report(p, "", severityError, "missing required field %q", "tags")
+	}
+	if p.Description() == "" {
+		l.report(p, "", severityWarning, "missing %q or %q, it is shown in lists and previews", "description", "summary")
+	}
+
+	l.lintDates(p)
+	l.lintDraft(p)
+	l.lintTaxonomy(p, "tags", l.tags)
+	l.lintTaxonomy(p, "series", l.series)
+	l.lintCover(p)
+}
+
+func (l *linter) lintKeys(p content.Page, parent string, params map[string]any) {
+	known, ok := knownKeys[parent]
+	if !ok {
+		return
+	}
+
+	for key, value := range params {
+		full := key
+		if parent != "" {
+			full = parent + "." + key
+		}
+
+		if !slices.Contains(known, key) {
+			msg := fmt.Sprintf("unknown field %q", full)
+			if s := suggest(key, known); s != "" {
+				msg += fmt.Sprintf(", did you mean %q?", s)
+			}
+			l.report(p, full, severityError, "%s", msg)
+			continue
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			l.lintKeys(p, key, nested)
+		}
+	}
+}
+
+func (l *linter) lintDates(p content.Page) {
+	for _, key := range []string{"date", "lastmod", "publishdate", "expirydate"} {
+		switch v := p.Param(key).(type) {
+		case nil, time.Time:
+		case string:
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				l.report(p, key, severityError, "%s %q is not an RFC 3339 date, like 2006-01-02T15:04:05+02:00", key, v)
+			}
+		default:
+			l.report(p, key, severityError, "%s must be an RFC 3339 date, got %T", key, v)
+		}
+	}
+}
+
+func (l *linter) lintDraft(p content.Page) {
+	switch v := p.Param("draft").(type) {
+	case nil, bool:
+	default:
+		l.report(p, "draft", severityError, "draft must be true or false, got %q", fmt.Sprint(v))
+		return
+	}
+
+	if p.Draft() {
+		return
+	}
+
+	// Hugo silently skips future pages without --buildFuture.
+	date, err := p.Date()
+	if err == nil && date.After(l.now) {
+		l.report(p, "date", severityError, "page is not a draft, but its date %s is in the future, it won't be published", date.Format(time.RFC3339))
+	}
+}
+
+func (l *linter) lintTaxonomy(p content.Page, key string, known []string) {
+	switch p.Param(key).(type) {
+	case nil, []any, []string:
+	default:
+		l.report(p, key, severityError, "%s must be a list", key)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, term := range p.Strings(key) {
+		if seen[strings.ToLower(term)] {
+			l.report(p, key, severityError, "duplicate %s %q", key, term)
+			continue
+		}
+		seen[strings.ToLower(term)] = true
+
+		if slices.Contains(known, term) {
+			continue
+		}
+
+		l.report(p, key, severityError, "%s", unknownTermMessage(key, term, known))
+	}
+}
+
+func unknownTermMessage(key, term string, known []string) string {
+	for _, k := range known {
+		if strings.EqualFold(k, term) {
+			return fmt.Sprintf("%s %q has different case, use %q", key, term, k)
+		}
+	}
+
+	if s := suggest(term, known); s != "" {
+		return fmt.Sprintf("unknown %s %q, did you mean %q?", key, term, s)
+	}
+
+	return fmt.Sprintf("unknown %s %q, add it to data/taxonomies.yaml if it is new", key, term)
+}
+
+func (l *linter) lintCover(p content.Page) {
+	if p.Param("cover") == nil {
+		return
+	}
+
+	image, ok := p.Param("cover.image").(string)
+	if !ok || image == "" {
+		l.report(p, "cover.image", severityWarning, "cover image is empty, set it or remove cover")
+		return
+	}
+	if strings.Contains(image, "://") {
+		return
+	}
+
+	// Page bundles have cover images as page resources, others come from assets/.
+	if p.IsBundle() {
+		if _, err := fs.Stat(l.content, path.Join(path.Dir(p.Path), image)); err == nil {
+			return
+		}
+	}
+	if _, err := fs.Stat(l.assets, strings.TrimPrefix(image, "/")); err != nil {
+		l.report(p, "cover.image", severityError, "cover image %q not found in assets/", image)
+	}
+}
+
+// suggest returns the closest candidate to s, if it is close enough to be a typo.
+func suggest(s string, candidates []string) string {
+	best, bestDist := "", 3 // at most 2 edits
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(s), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/content"
+)
+
+func TestLint(t *testing.T) {
+	contentFS := fstest.MapFS{
+		"blog/_index.md": {Data: []byte("---\ntitle: Blog\n---\n")},
+		"blog/ok.md": {Data: []byte(`---
+date: '2025-06-04T18:27:15+02:00'
+title: 'Golden Tests'
+slug: 'golden-tests'
+summary: 'Use golden files'
+cover:
+    image: 'images/golden-brick.svg'
+tags:
+    - Go
+    - testing
+---
+Body.
+`)},
+		"blog/bad.md": {Data: []byte(`---
+date: '2025-06-04'
+title: 'Duplicate'
+slug: 'golden-tests'
+sumary: 'typo'
+draft: 'no'
+cover:
+    image: 'images/missing.svg'
+tags:
+    - go
+    - testng
+    - kubernetes
+series:
+    - HTTP3
+---
+`)},
+		"blog/future.md": {Data: []byte(`+++
+date = 2030-01-01T00:00:00Z
+title = 'Future'
+slug = 'future'
+description = 'From the future'
+tags = ['Go']
++++
+`)},
+		"blog/bundle/index.md": {Data: []byte(`---
+date: '2025-05-28T22:02:23+02:00'
+title: 'Webview'
+slug: 'webview'
+description: 'Bundle'
+cover:
+    image: 'index_page.png'
+tags: [Go]
+---
+`)},
+		"blog/bundle/index_page.png": {Data: []byte("png")},
+		"howto/empty.md":             {Data: []byte("---\ndraft: true\n---\n")},
+	}
+
+	pages, err := content.Load(contentFS)
+	if err != nil {
+		t.Fatalf("load pages: %s", err)
+	}
+
+	l := linter{
+		assets:  fstest.MapFS{"images/golden-brick.svg": {Data: []byte("<svg/>")}},
+		content: contentFS,
+		tags:    []string{"Go", "testing"},
+		series:  []string{"HTTP3"},
+		now:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var got []string
+	for _, d := range l.Lint(pages) {
+		got = append(got, d.String())
+	}
+
+	want := []string{
+		`blog/bad.md:1: warning: missing "description" or "summary", it is shown in lists and previews`,
+		`blog/bad.md:2: error: date "2025-06-04" is not an RFC 3339 date, like 2006-01-02T15:04:05+02:00`,
+		`blog/bad.md:5: error: unknown field "sumary", did you mean "summary"?`,
+		`blog/bad.md:6: error: draft must be true or false, got "no"`,
+		`blog/bad.md:8: error: cover image "images/missing.svg" not found in assets/`,
+		`blog/bad.md:9: error: tags "go" has different case, use "Go"`,
+		`blog/bad.md:9: error: unknown tags "testng", did you mean "testing"?`,
+		`blog/bad.md:9: error: unknown tags "kubernetes", add it to data/taxonomies.yaml if it is new`,
+		`blog/future.md:2: error: page is not a draft, but its date 2030-01-01T00:00:00Z is in the future, it won't be published`,
+		`blog/ok.md:4: error: slug "golden-tests" is already used by blog/bad.md`,
+		`howto/empty.md:1: error: missing required field "title"`,
+		`howto/empty.md:1: error: missing required field "slug"`,
+		`howto/empty.md:1: error: missing required field "date"`,
+		`howto/empty.md:1: error: missing required field "tags"`,
+		`howto/empty.md:1: warning: missing "description" or "summary", it is shown in lists and previews`,
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,83 @@
+// Command lint-content validates front matter of pages in content/ directory.
+//
+// It checks required fields, dates, unique slugs, known tags and series from data/taxonomies.yaml,
+// cover images and drafts. Problems are printed as file:line diagnostics,
+// exit code is non-zero if there are errors.
+//
+// Run it from the repository root:
+//
+//	go run ./tools/lint-content
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dmksnnk/blog/internal/content"
+	"gopkg.in/yaml.v3"
+)
+
+type taxonomies struct {
+	Tags   []string `yaml:"tags"`
+	Series []string `yaml:"series"`
+}
+
+func main() {
+	contentDir := flag.String("content", "content", "Hugo content directory")
+	assetsDir := flag.String("assets", "assets", "Hugo assets directory")
+	taxonomiesFile := flag.String("taxonomies", "data/taxonomies.yaml", "file with known tags and series")
+	flag.Parse()
+
+	known, err := readTaxonomies(*taxonomiesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read taxonomies: %s\n", err)
+		os.Exit(2)
+	}
+
+	contentFS := os.DirFS(*contentDir)
+	pages, err := content.Load(contentFS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	l := linter{
+		assets:  os.DirFS(*assetsDir),
+		content: contentFS,
+		tags:    known.Tags,
+		series:  known.Series,
+		now:     time.Now(),
+	}
+
+	var errors int
+	for _, d := range l.Lint(pages) {
+		d.Path = filepath.Join(*contentDir, filepath.FromSlash(d.Path))
+		fmt.Println(d)
+		if d.Severity == severityError {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		fmt.Fprintf(os.Stderr, "%d error(s) in %d page(s)\n", errors, len(pages))
+		os.Exit(1)
+	}
+}
+
+func readTaxonomies(name string) (taxonomies, error) {
+	var t taxonomies
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return t, err
+	}
+
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("parse %s: %w", name, err)
+	}
+
+	return t, nil
+}
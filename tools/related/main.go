@@ -0,0 +1,67 @@
+// Command related generates related posts index from pages in content/ directory.
+//
+// Posts are related by shared tags, series and TF-IDF similarity of their text.
+// The index is written to data/related.json, where the theme reads it from site.Data.related,
+// and it is embedded into the server for the /api/related endpoint.
+// With -check it only verifies that the index is up to date.
+//
+// Run it from the repository root:
+//
+//	go run ./tools/related [-check]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dmksnnk/blog/internal/content"
+	"github.com/dmksnnk/blog/internal/related"
+)
+
+func main() {
+	contentDir := flag.String("content", "content", "Hugo content directory")
+	out := flag.String("out", "data/related.json", "output file")
+	limit := flag.Int("limit", related.DefaultOptions.Limit, "maximum number of related posts per post")
+	check := flag.Bool("check", false, "check that output file is up to date instead of writing it")
+	flag.Parse()
+
+	pages, err := content.Load(os.DirFS(*contentDir))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	opts := related.DefaultOptions
+	opts.Limit = *limit
+	idx := related.Build(pages, opts)
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encode index: %s\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+
+	if *check {
+		current, err := os.ReadFile(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		if !bytes.Equal(current, data) {
+			fmt.Fprintf(os.Stderr, "%s is out of date, run go run ./tools/related\n", *out)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("wrote related posts for %d post(s) to %s\n", len(idx), *out)
+}
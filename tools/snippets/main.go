@@ -0,0 +1,147 @@
+// Command snippets keeps code blocks in posts in sync with sources in examples/.
+//
+// A fenced code block is included from source when it is preceded by a marker:
+//
+//	<!-- snippet: examples/certs/selfsigned.go#template -->
+//
+// The part after # is a region in the source, marked with "// snippet:start template" and
+// "// snippet:end template" comments, or the name of a Go function, method (Type.Method) or type.
+// Without # the whole file is included. Code is indented as the block in the post:
+// with tabs or 4 spaces, which can be forced with indent=tabs or indent=spaces option.
+// Go blocks without marker are checked to parse and to be gofmt-ed, including standalone statements,
+// use "<!-- snippet: skip -->" for intentionally incomplete code.
+//
+// By default, it checks that posts are up to date. With -write it rewrites code blocks from sources.
+// Run it from the repository root:
+//
+//	go run ./tools/snippets [-write]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func main() {
+	contentDir := flag.String("content", "content", "Hugo content directory")
+	root := flag.String("root", ".", "directory snippet paths are relative to")
+	write := flag.Bool("write", false, "rewrite code blocks from sources instead of checking them")
+	flag.Parse()
+
+	s := syncer{
+		root:  os.DirFS(*root),
+		write: *write,
+	}
+
+	err := filepath.WalkDir(*contentDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".md" {
+			return nil
+		}
+
+		return s.syncFile(name)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+
+	for _, p := range s.problems {
+		fmt.Println(p)
+	}
+	if len(s.problems) > 0 {
+		if !*write {
+			fmt.Fprintln(os.Stderr, "run with -write to update snippets from sources")
+		}
+		os.Exit(1)
+	}
+}
+
+type syncer struct {
+	root  fs.FS
+	write bool
+
+	problems []string
+}
+
+func (s *syncer) report(name string, line int, format string, args ...any) {
+	s.problems = append(s.problems, fmt.Sprintf("%s:%d: %s", name, line, fmt.Sprintf(format, args...)))
+}
+
+func (s *syncer) syncFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	blocks := parseBlocks(lines)
+	changed := false
+	reported := len(s.problems)
+
+	// from the end, so replacing lines doesn't shift next blocks
+	for i := len(blocks) - 1; i >= 0; i-- {
+		b := blocks[i]
+
+		switch b.Marker {
+		case "skip":
+			continue
+		case "":
+			if b.Lang == "go" {
+				if err := checkGo(b.Code); err != nil {
+					s.report(name, b.Line, "go block %s", err)
+				}
+			}
+			continue
+		}
+
+		code, err := resolve(s.root, b.Marker)
+		if err != nil {
+			s.report(name, b.Line, "snippet %s: %s", b.Marker, err)
+			continue
+		}
+
+		style := b.Options["indent"]
+		if style == "" && usesSpaces(b.Code) {
+			style = "spaces"
+		}
+		want := indent(code, style)
+		if want == b.Code {
+			continue
+		}
+
+		if !s.write {
+			s.report(name, b.Line, "snippet differs from %s", b.Marker)
+			continue
+		}
+
+		var replaced []string
+		for _, l := range strings.SplitAfter(strings.TrimSuffix(want, "\n"), "\n") {
+			if strings.TrimSpace(l) == "" {
+				replaced = append(replaced, strings.TrimRight(l, " \t"))
+				continue
+			}
+			replaced = append(replaced, b.Indent+l)
+		}
+		replaced[len(replaced)-1] += "\n"
+
+		lines = append(lines[:b.start], append(replaced, lines[b.end:]...)...)
+		changed = true
+		fmt.Printf("%s:%d: updated from %s\n", name, b.Line, b.Marker)
+	}
+	slices.Reverse(s.problems[reported:]) // report in file order
+
+	if !changed {
+		return nil
+	}
+
+	return os.WriteFile(name, []byte(strings.Join(lines, "")), 0o644)
+}
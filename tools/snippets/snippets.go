@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// markerRe matches include marker, which must be on the line right before a fenced code block:
+//
+//	<!-- snippet: examples/certs/selfsigned.go#template -->
+//	<!-- snippet: examples/golden/api.go#API.Greet indent=spaces -->
+//	<!-- snippet: skip -->
+var markerRe = regexp.MustCompile(`^\s*<!--\s*snippet:\s*(\S+)((?:\s+\w+=\S+)*)\s*-->\s*$`)
+
+// regionRe matches region markers in source files:
+//
+//	// snippet:start template
+//	...
+//	// snippet:end template
+var regionRe = regexp.MustCompile(`^\s*(?://|#)\s*snippet:(start|end)\s+(\S+)\s*$`)
+
+var fenceRe = regexp.MustCompile("^(\\s*)(`{3,}|~{3,})\\s*([\\w+-]*)")
+
+// block is a fenced code block in a Markdown file.
+type block struct {
+	// Line is the line number of the opening fence.
+	Line   int
+	Indent string
+	Lang   string
+	// Marker is the include marker before the block, empty if there is none.
+	Marker  string
+	Options map[string]string
+	// Code is the content of the block without fence indentation.
+	Code string
+
+	start, end int // line indexes of the content, end is the closing fence
+}
+
+// parseBlocks finds fenced code blocks in Markdown.
+func parseBlocks(lines []string) []block {
+	var blocks []block
+	for i := 0; i < len(lines); i++ {
+		m := fenceRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+
+		b := block{Line: i + 1, Indent: m[1], Lang: m[3], start: i + 1, end: len(lines)}
+		if i > 0 {
+			if mm := markerRe.FindStringSubmatch(lines[i-1]); mm != nil {
+				b.Marker = mm[1]
+				b.Options = parseOptions(mm[2])
+			}
+		}
+
+		fence := m[2]
+		for j := i + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				b.end = j
+				break
+			}
+		}
+
+		var code strings.Builder
+		for _, l := range lines[b.start:b.end] {
+			code.WriteString(strings.TrimPrefix(l, b.Indent))
+		}
+		b.Code = code.String()
+
+		blocks = append(blocks, b)
+		i = b.end
+	}
+
+	return blocks
+}
+
+func parseOptions(s string) map[string]string {
+	opts := make(map[string]string)
+	for _, f := range strings.Fields(s) {
+		k, v, _ := strings.Cut(f, "=")
+		opts[k] = v
+	}
+
+	return opts
+}
+
+// resolve returns snippet for the marker, e.g. "examples/certs/selfsigned.go#template".
+// Name after # is a region or, for Go files, a function, method (Type.Method) or type.
+func resolve(fsys fs.FS, marker string) (string, error) {
+	file, name, _ := strings.Cut(marker, "#")
+
+	src, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		code := stripRegionMarkers(string(src))
+		if code != "" && !strings.HasSuffix(code, "\n") { // code blocks end with a new line
+			code += "\n"
+		}
+		return code, nil
+	}
+
+	if code, ok := region(string(src), name); ok {
+		// gofmt keeps a blank line before a marker after a declaration
+		return dedent(strings.TrimRight(code, "\n") + "\n"), nil
+	}
+
+	if path.Ext(file) == ".go" {
+		code, err := goDecl(file, src, name)
+		if err != nil {
+			return "", err
+		}
+		return stripRegionMarkers(code), nil
+	}
+
+	return "", fmt.Errorf("region %q not found in %s", name, file)
+}
+
+func region(src, name string) (string, bool) {
+	var out strings.Builder
+	inside, found := false, false
+	for _, l := range strings.SplitAfter(src, "\n") {
+		m := regionRe.FindStringSubmatch(l)
+		if m != nil {
+			if m[2] == name {
+				inside = m[1] == "start"
+				found = true
+			}
+			continue // drop all region markers
+		}
+		if inside {
+			out.WriteString(l)
+		}
+	}
+
+	return out.String(), found
+}
+
+func stripRegionMarkers(src string) string {
+	var out strings.Builder
+	for _, l := range strings.SplitAfter(src, "\n") {
+		if !regionRe.MatchString(l) {
+			out.WriteString(l)
+		}
+	}
+
+	return out.String()
+}
+
+// goDecl returns source of the top-level declaration with its doc comment.
+func goDecl(filename string, src []byte, name string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	recv, fn, isMethod := strings.Cut(name, ".")
+	for _, decl := range f.Decls {
+		var doc *ast.CommentGroup
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !isMethod && d.Recv == nil && d.Name.Name == name ||
+				isMethod && d.Recv != nil && d.Name.Name == fn && receiverType(d.Recv) == recv {
+				doc = d.Doc
+			} else {
+				continue
+			}
+		case *ast.GenDecl:
+			if isMethod || !declares(d, name) {
+				continue
+			}
+			doc = d.Doc
+		default:
+			continue
+		}
+
+		start := decl.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+
+		return string(src[fset.Position(start).Offset:fset.Position(decl.End()).Offset]) + "\n", nil
+	}
+
+	return "", fmt.Errorf("declaration %q not found in %s", name, filename)
+}
+
+func receiverType(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if idx, ok := expr.(*ast.IndexExpr); ok { // generic receiver
+		expr = idx.X
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+
+	return ""
+}
+
+func declares(d *ast.GenDecl, name string) bool {
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if s.Name.Name == name {
+				return true
+			}
+		case *ast.ValueSpec:
+			for _, n := range s.Names {
+				if n.Name == name {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// dedent removes common leading whitespace.
+func dedent(code string) string {
+	lines := strings.SplitAfter(code, "\n")
+	prefix := ""
+	first := true
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	var out strings.Builder
+	for _, l := range lines {
+		out.WriteString(strings.TrimPrefix(l, prefix))
+	}
+
+	return out.String()
+}
+
+// usesSpaces reports whether code is indented with spaces instead of tabs.
+func usesSpaces(code string) bool {
+	spaces := false
+	for _, l := range strings.Split(code, "\n") {
+		if strings.HasPrefix(l, "\t") {
+			return false
+		}
+		if strings.HasPrefix(l, "  ") {
+			spaces = true
+		}
+	}
+
+	return spaces
+}
+
+// indent formats leading tabs of code according to style: "tabs" or "spaces" (4 spaces per tab).
+func indent(code, style string) string {
+	if style != "spaces" {
+		return code
+	}
+
+	lines := strings.SplitAfter(code, "\n")
+	for i, l := range lines {
+		trimmed := strings.TrimLeft(l, "\t")
+		lines[i] = strings.Repeat("    ", len(l)-len(trimmed)) + trimmed
+	}
+
+	return strings.Join(lines, "")
+}
+
+// checkGo checks that standalone snippet is valid Go: a file, declarations or statements,
+// and that it is gofmt-ed.
+func checkGo(code string) error {
+	src := elide(tabIndent(code))
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, 0); err == nil {
+		return checkFormat(src, "")
+	}
+
+	const declPrefix = "package snippet\n\n"
+	if _, err := parser.ParseFile(token.NewFileSet(), "", declPrefix+src, 0); err == nil {
+		return checkFormat(src, declPrefix)
+	}
+
+	// statements are indented into the body of a function, so gofmt sees them as it would in a file
+	const stmtPrefix = "package snippet\n\nfunc _() {\n"
+	body := indentBody(src)
+	_, err := parser.ParseFile(token.NewFileSet(), "", stmtPrefix+body+"}\n", 0)
+	if err == nil {
+		return checkFormat(body+"}\n", stmtPrefix)
+	}
+
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return fmt.Errorf("does not parse as Go: %s", list[0].Msg)
+	}
+	return fmt.Errorf("does not parse as Go: %w", err)
+}
+
+// checkFormat compares code with gofmt output.
+func checkFormat(src, prefix string) error {
+	formatted, err := format.Source([]byte(prefix + src))
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(formatted), bytes.TrimSpace([]byte(prefix+src))) {
+		return errors.New("is not gofmt-ed")
+	}
+
+	return nil
+}
+
+// indentBody indents non-empty lines with a tab and ends code with a new line.
+func indentBody(code string) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(code, "\n")+"\n", "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			lines[i] = "\t" + l
+		}
+	}
+
+	return strings.Join(lines, "")
+}
+
+var elisionRe = regexp.MustCompile(`(?m)^(\s*)\.\.\.\s*(?://.*)?$`)
+
+// elide replaces "..." used in posts for omitted code with valid Go:
+// lines with "..." and an optional comment become comments, "{...}" and "(...)" become empty.
+func elide(code string) string {
+	code = elisionRe.ReplaceAllString(code, "$1// ...")
+	code = strings.ReplaceAll(code, "{...}", "{}")
+	return strings.ReplaceAll(code, "(...)", "()")
+}
+
+// tabIndent converts 4-space indentation back to tabs, so gofmt check works for snippets in posts.
+func tabIndent(code string) string {
+	if !usesSpaces(code) {
+		return code
+	}
+
+	lines := strings.SplitAfter(code, "\n")
+	for i, l := range lines {
+		trimmed := strings.TrimLeft(l, " ")
+		n := (len(l) - len(trimmed)) / 4
+		lines[i] = strings.Repeat("\t", n) + l[n*4:]
+	}
+
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const source = `package main
+
+// snippet:start hello
+// Hello says hello.
+func Hello() string {
+	return "hello"
+}
+
+// snippet:end hello
+
+type Greeter struct{}
+
+// Greet greets.
+func (g *Greeter) Greet(name string) string {
+	return "hello, " + name
+}
+
+func main() {
+	// snippet:start body
+	msg := Hello()
+	if msg != "" {
+		println(msg)
+	}
+	// snippet:end body
+}
+`
+
+func TestResolve(t *testing.T) {
+	fsys := fstest.MapFS{
+		"examples/hello/main.go":      {Data: []byte(source)},
+		"examples/hello/request.json": {Data: []byte("{\n    \"name\": \"John\"\n}")},
+	}
+
+	tests := []struct {
+		marker string
+		want   string
+	}{
+		{
+			marker: "examples/hello/main.go#body",
+			want:   "msg := Hello()\nif msg != \"\" {\n\tprintln(msg)\n}\n",
+		},
+		{
+			marker: "examples/hello/main.go#hello",
+			want:   "// Hello says hello.\nfunc Hello() string {\n\treturn \"hello\"\n}\n",
+		},
+		{
+			marker: "examples/hello/main.go#Hello",
+			want:   "// Hello says hello.\nfunc Hello() string {\n\treturn \"hello\"\n}\n",
+		},
+		{
+			marker: "examples/hello/main.go#Greeter.Greet",
+			want:   "// Greet greets.\nfunc (g *Greeter) Greet(name string) string {\n\treturn \"hello, \" + name\n}\n",
+		},
+		{
+			marker: "examples/hello/main.go#Greeter",
+			want:   "type Greeter struct{}\n",
+		},
+		{
+			marker: "examples/hello/request.json",
+			want:   "{\n    \"name\": \"John\"\n}\n",
+		},
+		{
+			marker: "examples/hello/main.go#main",
+			want:   "func main() {\n\tmsg := Hello()\n\tif msg != \"\" {\n\t\tprintln(msg)\n\t}\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.marker, func(t *testing.T) {
+			got, err := resolve(fsys, tt.marker)
+			if err != nil {
+				t.Fatalf("resolve: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := resolve(fsys, "examples/hello/main.go#Missing"); err == nil {
+		t.Errorf("expected error for missing declaration")
+	}
+}
+
+func TestParseBlocks(t *testing.T) {
+	md := "Text\n\n<!-- snippet: examples/hello/main.go#body indent=spaces -->\n```go\nold\n```\n\n- item\n\n  ~~~sh\n  go test\n  ~~~\n"
+
+	blocks := parseBlocks(strings.SplitAfter(md, "\n"))
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(blocks))
+	}
+
+	b := blocks[0]
+	if b.Line != 4 || b.Lang != "go" || b.Marker != "examples/hello/main.go#body" || b.Options["indent"] != "spaces" || b.Code != "old\n" {
+		t.Errorf("unexpected first block %+v", b)
+	}
+
+	b = blocks[1]
+	if b.Line != 10 || b.Indent != "  " || b.Lang != "sh" || b.Marker != "" || b.Code != "go test\n" {
+		t.Errorf("unexpected second block %+v", b)
+	}
+}
+
+func TestCheckGo(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		wantErr string
+	}{
+		{name: "file", code: "package main\n\nfunc main() {}\n"},
+		{name: "declarations", code: "func main() {\n    run()\n}\n"},
+		{name: "statements", code: "conn, err := dial()\nif err != nil {\n    ... // handle error\n}\n"},
+		{name: "elided", code: "func run(ctx context.Context) error {...}\n"},
+		{name: "syntax error", code: "w.Write(data),\n", wantErr: "does not parse as Go"},
+		{name: "not formatted", code: "func main() {\nrun()\n}\n", wantErr: "is not gofmt-ed"},
+		{name: "statements not formatted", code: "if err != nil {\n    return err\n\t}\n", wantErr: "is not gofmt-ed"},
+		{name: "statements misaligned", code: "x := map[string]int{\n    \"a\": 1,\n    \"bb\":  2,\n}\n", wantErr: "is not gofmt-ed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGo(tt.code)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	code := "func main() {\n\tif ok {\n\t\trun()\n\t}\n}\n"
+
+	got := indent(code, "spaces")
+	if want := "func main() {\n    if ok {\n        run()\n    }\n}\n"; got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if back := tabIndent(got); back != code {
+		t.Errorf("expected tabs back, got:\n%s", back)
+	}
+}